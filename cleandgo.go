@@ -1,8 +1,11 @@
 package cleandgo
 
 import (
+	"io/fs"
+
 	it "github.com/faelmori/cleandgo/interfaces"
 	t "github.com/faelmori/cleandgo/types"
+	utl "github.com/faelmori/cleandgo/utils"
 	l "github.com/faelmori/logz"
 	"github.com/google/uuid"
 )
@@ -10,6 +13,10 @@ import (
 type FileTree = it.IFileTree
 type FileTreeType = t.FileTree
 
+type FileTreeOptions = t.FileTreeOptions
+
+type FileSystem = it.IFileSystem
+
 type FileEntry = it.IFileEntry
 type FileEntryType = t.FileEntry
 
@@ -17,6 +24,102 @@ func NewFileTree(treeFileSource, composerTargetPath string, printTree bool, logg
 	return t.NewFileTree(treeFileSource, composerTargetPath, printTree, logger, debug)
 }
 
+func NewFileTreeWithOptions(treeFileSource, composerTargetPath string, printTree bool, logger l.Logger, debug bool, options *FileTreeOptions) (it.IFileTree, error) {
+	return t.NewFileTreeWithOptions(treeFileSource, composerTargetPath, printTree, logger, debug, options)
+}
+
+func LoadTreeValues(sets []string, envFile, valuesFile string) (map[string]any, error) {
+	return t.LoadTreeValues(sets, envFile, valuesFile)
+}
+
+func LoadTreeProfile(profilePath string) (*t.TreeProfile, error) {
+	return t.LoadTreeProfile(profilePath)
+}
+
+func ApplyTreeOverlay(ft FileTree, overlayPath string) error {
+	return t.ApplyTreeOverlay(ft, overlayPath)
+}
+
 func NewFileEntry(id, parentID uuid.UUID, entryType, name, originName string, depth int, size int64, comments string) (it.IFileEntry, error) {
 	return t.NewFileEntry(id, parentID, entryType, name, originName, depth, size, comments)
 }
+
+func LoadSkeletonDir(skeletonDir string) (map[string]string, error) {
+	return t.LoadSkeletonDir(skeletonDir)
+}
+
+func LoadTemplatePack(fsys fs.FS, source string) (*t.TemplatePack, error) {
+	return t.LoadTemplatePack(fsys, source)
+}
+
+func LoadTemplatePackDir(packDir string) (*t.TemplatePack, error) {
+	return t.LoadTemplatePackDir(packDir)
+}
+
+func ImportMarkdownTree(markdownPath, composerTargetPath string, options *FileTreeOptions) (FileTree, *t.MarkdownImportReport, error) {
+	return t.ImportMarkdownTree(markdownPath, composerTargetPath, options)
+}
+
+func ScanDirectoryTree(dir string, options *t.DirectoryScanOptions) (FileTree, error) {
+	return t.ScanDirectoryTree(dir, options)
+}
+
+func ReadArchiveTree(archivePath string, options *t.ArchiveTreeOptions) (FileTree, error) {
+	return t.ReadArchiveTree(archivePath, options)
+}
+
+func RenderTreeView(ft FileTree, options *t.TreeRenderOptions) string {
+	return t.RenderTreeView(ft, options)
+}
+
+func UndoTreeJournal(targetDir string, options *t.JournalUndoOptions) (*t.JournalUndoReport, error) {
+	return t.UndoTreeJournal(targetDir, options)
+}
+
+func NewTreeEdit(dir string, options *t.TreeEditOptions) (*t.TreeEdit, error) {
+	return t.NewTreeEdit(dir, options)
+}
+
+func DetectTreeDrift(ft FileTree, dir string, options *t.TreeDriftOptions) (*t.TreeDriftReport, error) {
+	return t.DetectTreeDrift(ft, dir, options)
+}
+
+func DetectArchiveDrift(ft FileTree, archivePath string, options *t.TreeDriftOptions) (*t.TreeDriftReport, error) {
+	return t.DetectArchiveDrift(ft, archivePath, options)
+}
+
+func DiffTrees(oldTree, newTree FileTree) (*t.TreeDiff, error) {
+	return t.DiffTrees(oldTree, newTree)
+}
+
+func MergeTrees(baseTree, oursTree, theirsTree FileTree) (*t.TreeMerge, error) {
+	return t.MergeTrees(baseTree, oursTree, theirsTree)
+}
+
+func LoadTreeSource(treeFile string) (FileTree, error) {
+	return t.LoadTreeSource(treeFile)
+}
+
+func ParseTreeQuery(text string) (*t.TreeQuery, error) {
+	return t.ParseTreeQuery(text)
+}
+
+func QueryTree(ft FileTree, query *t.TreeQuery) []it.IFileEntry {
+	return t.QueryTree(ft, query)
+}
+
+func LoadTreeLock(fsys FileSystem, targetDir string) (*t.TreeLock, error) {
+	return t.LoadTreeLock(fsys, targetDir)
+}
+
+func NewOsFileSystem() FileSystem {
+	return utl.NewOsFileSystem()
+}
+
+func NewMemFileSystem() FileSystem {
+	return utl.NewMemFileSystem()
+}
+
+func NewBasePathFileSystem(base string) FileSystem {
+	return utl.NewBasePathFileSystem(base)
+}
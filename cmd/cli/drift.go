@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func diffCommand() *cobra.Command {
+	var treeFileSource, dir, placeholder, format string
+	var valueSets, features, ignore []string
+	var valuesFile string
+	var stripComponents int
+	var noColor, full bool
+
+	var diffCmd = &cobra.Command{
+		Use: "diff",
+		Annotations: GetDescriptions([]string{
+			"Report the drift between a tree view file and a directory, or the changes between two trees",
+			"With --tree and --dir, this command compares a tree view file with a directory (or a tar, tar.gz or zip archive) and reports the entries missing on disk, the extras on disk, the entries of the wrong type and, when annotated, of the wrong mode or checksum, flagging the likely renames. With two tree view files, directories or archives as arguments, it reports the entries added, removed, moved, renamed and with changed comments or metadata. It exits with 1 when there are differences and 2 when the comparison failed",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if noColor {
+				color.NoColor = true
+			}
+			if len(args) == 2 {
+				values, valuesErr := t.LoadTreeValues(valueSets, "", valuesFile)
+				if valuesErr != nil {
+					gl.Log("error", fmt.Sprintf("Failed to load tree values: %s", valuesErr))
+					os.Exit(2)
+				}
+				os.Exit(diffTrees(args[0], args[1], ignore, stripComponents, &t.FileTreeOptions{Values: values, Features: features}, format, full))
+			}
+			if treeFileSource == "" || dir == "" {
+				gl.Log("error", "Both the tree view file and the directory or archive are required")
+				os.Exit(2)
+			}
+			values, valuesErr := t.LoadTreeValues(valueSets, "", valuesFile)
+			if valuesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load tree values: %s", valuesErr))
+				os.Exit(2)
+			}
+			ft, ftErr := t.NewFileTreeWithOptions(treeFileSource, dir, false, nil, false, &t.FileTreeOptions{Values: values, Features: features})
+			if ftErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to parse tree: %s", ftErr))
+				os.Exit(2)
+			}
+			placeholders := make([]string, 0)
+			if placeholder != "" {
+				placeholders = append(placeholders, placeholder)
+			}
+			driftOptions := &t.TreeDriftOptions{Ignore: ignore, Placeholders: placeholders, StripComponents: stripComponents}
+			var report *t.TreeDriftReport
+			var driftErr error
+			if _, formatErr := t.ArchiveFormat(dir); formatErr == nil {
+				report, driftErr = t.DetectArchiveDrift(ft, dir, driftOptions)
+			} else {
+				report, driftErr = t.DetectTreeDrift(ft, dir, driftOptions)
+			}
+			if driftErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to compare the tree with '%s': %s", dir, driftErr))
+				os.Exit(2)
+			}
+			switch format {
+			case "json":
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					gl.Log("error", fmt.Sprintf("Failed to encode the report: %s", err))
+					os.Exit(2)
+				}
+				fmt.Println(string(data))
+			case "text":
+				printTreeDrift(report)
+			default:
+				gl.Log("error", fmt.Sprintf("Unknown format '%s', use text or json", format))
+				os.Exit(2)
+			}
+			if report.HasDrift() {
+				os.Exit(1)
+			}
+		},
+	}
+
+	diffCmd.Flags().StringVarP(&treeFileSource, "tree", "t", "", "Path to the tree view file")
+	diffCmd.Flags().StringVar(&dir, "dir", "", "Path to the directory or archive compared with the tree")
+	diffCmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	diffCmd.Flags().BoolVar(&noColor, "no-color", false, "Do not color the text output")
+	diffCmd.Flags().BoolVar(&full, "full", false, "Between two trees, draw the unchanged subtrees too")
+	diffCmd.Flags().IntVar(&stripComponents, "strip-components", 0, "Remove leading path components of the archive members")
+	diffCmd.Flags().StringArrayVar(&ignore, "ignore", []string{}, "Leave out the paths or names matching a glob, can be repeated")
+	diffCmd.Flags().StringVar(&placeholder, "placeholder", "", "Placeholder file name not reported as an extra (besides .gitkeep and .keep)")
+	diffCmd.Flags().StringArrayVar(&valueSets, "set", []string{}, "Set a template variable (k=v), can be repeated")
+	diffCmd.Flags().StringVar(&valuesFile, "values", "", "Path to a YAML file with template variables")
+	diffCmd.Flags().StringArrayVar(&features, "feature", []string{}, "Enable a feature for the # if:/# unless: entries, can be repeated")
+
+	return diffCmd
+}
+
+// printTreeDrift prints the drifts of a report, colored by kind, followed by a summary line.
+func printTreeDrift(report *t.TreeDriftReport) {
+	for _, drift := range report.Drifts {
+		switch drift.Kind {
+		case "missing":
+			color.Red("- %s", drift)
+		case "extra":
+			color.Green("+ %s", drift)
+		case "rename":
+			color.Cyan("? %s", drift)
+		default:
+			color.Yellow("~ %s", drift)
+		}
+	}
+	if !report.HasDrift() {
+		color.Green("%s matches the tree (%d entries checked)", report.Dir, report.Checked)
+		return
+	}
+	fmt.Printf("%s drifted from the tree: %d differences in %d entries checked\n", report.Dir, len(report.Drifts), report.Checked)
+}
+
+// diffTrees prints the structural diff between two trees read from tree view files, directories or archives,
+// returning the exit code: 0 when they match, 1 when they differ and 2 on failure.
+func diffTrees(oldSource, newSource string, ignore []string, stripComponents int, treeOptions *t.FileTreeOptions, format string, full bool) int {
+	oldTree, oldErr := loadInspectedTree(oldSource, ignore, stripComponents, treeOptions)
+	if oldErr != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read '%s': %s", oldSource, oldErr))
+		return 2
+	}
+	newTree, newErr := loadInspectedTree(newSource, ignore, stripComponents, treeOptions)
+	if newErr != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read '%s': %s", newSource, newErr))
+		return 2
+	}
+	diff, diffErr := t.DiffTrees(oldTree, newTree)
+	if diffErr != nil {
+		gl.Log("error", fmt.Sprintf("Failed to compare the trees: %s", diffErr))
+		return 2
+	}
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(diff.Changes, "", "  ")
+		if err != nil {
+			gl.Log("error", fmt.Sprintf("Failed to encode the changes: %s", err))
+			return 2
+		}
+		fmt.Println(string(data))
+	case "text":
+		fmt.Printf("--- %s\n+++ %s\n", oldSource, newSource)
+		for _, line := range strings.Split(strings.TrimSuffix(diff.Render(&t.TreeDiffRenderOptions{Full: full}), "\n"), "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				color.Green("%s", line)
+			case strings.HasPrefix(line, "-"):
+				color.Red("%s", line)
+			case strings.HasPrefix(line, ">"):
+				color.Cyan("%s", line)
+			case strings.HasPrefix(line, "~"):
+				color.Yellow("%s", line)
+			default:
+				fmt.Println(line)
+			}
+		}
+	default:
+		gl.Log("error", fmt.Sprintf("Unknown format '%s', use text or json", format))
+		return 2
+	}
+	if len(diff.Changes) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func editCommand() *cobra.Command {
+	var dir, editor, quarantineDir string
+	var ignore []string
+	var yes, dryRun bool
+
+	var editCmd = &cobra.Command{
+		Use: "edit",
+		Annotations: GetDescriptions([]string{
+			"Restructure a directory by editing its tree view in $EDITOR",
+			"This command scans a directory into a tree view where every entry carries an @id annotation and opens it in $EDITOR. When the view is saved, the entries whose lines moved are moved or renamed, the lines without an @id are created and the entries left out are deleted, after a preview",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if dir == "" && len(args) > 0 {
+				dir = args[0]
+			}
+			if dir == "" {
+				dir = "."
+			}
+			te, editErr := t.NewTreeEdit(dir, &t.TreeEditOptions{Ignore: ignore, QuarantineDir: quarantineDir, DryRun: dryRun})
+			if editErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to scan '%s': %s", dir, editErr))
+				return
+			}
+			view := te.Render()
+			viewFile, tmpErr := os.CreateTemp("", "cleandgo-edit-*.txt")
+			if tmpErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to create the tree view file: %s", tmpErr))
+				return
+			}
+			viewPath := viewFile.Name()
+			_, writeErr := viewFile.WriteString(view)
+			viewFile.Close()
+			if writeErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to write the tree view file: %s", writeErr))
+				return
+			}
+			if err := runEditor(editor, viewPath); err != nil {
+				gl.Log("error", fmt.Sprintf("Failed to run the editor: %s", err))
+				return
+			}
+			edited, readErr := os.ReadFile(viewPath)
+			if readErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to read the edited tree view: %s", readErr))
+				return
+			}
+			if string(edited) == view {
+				os.Remove(viewPath)
+				gl.Log("info", "The tree view was not changed, nothing to do")
+				return
+			}
+			ops, planErr := te.Plan(string(edited))
+			if planErr != nil {
+				// A visão editada é mantida, para corrigir e tentar de novo
+				gl.Log("error", fmt.Sprintf("Invalid tree view (kept at %s): %s", viewPath, planErr))
+				return
+			}
+			os.Remove(viewPath)
+			if len(ops) == 0 {
+				gl.Log("info", "The edited tree view matches the directory, nothing to do")
+				return
+			}
+			for _, op := range ops {
+				fmt.Println(op.String())
+			}
+			if dryRun {
+				gl.Log("success", fmt.Sprintf("Dry run finished, %d changes planned", len(ops)))
+				return
+			}
+			if !yes && !confirmPrompt(fmt.Sprintf("Apply %d changes to %s? [y/N] ", len(ops), te.Dir)) {
+				gl.Log("info", "Nothing was changed")
+				return
+			}
+			if err := te.Apply(ops); err != nil {
+				gl.Log("error", fmt.Sprintf("Failed to apply the changes: %s", err))
+				return
+			}
+			gl.Log("success", fmt.Sprintf("%d changes applied to %s", len(ops), te.Dir))
+		},
+	}
+
+	editCmd.Flags().StringVar(&dir, "dir", "", "Path to the directory to restructure (the current directory by default)")
+	editCmd.Flags().StringVar(&editor, "editor", "", "Editor command, instead of $VISUAL or $EDITOR")
+	editCmd.Flags().StringArrayVar(&ignore, "ignore", []string{}, "Leave out the paths or names matching a glob, can be repeated")
+	editCmd.Flags().StringVar(&quarantineDir, "quarantine", "", "Move the deleted paths to this directory instead of removing them")
+	editCmd.Flags().Lookup("quarantine").NoOptDefVal = t.DefaultQuarantineDir
+	editCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Apply the changes without asking")
+	editCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show the changes")
+
+	return editCmd
+}
+
+// runEditor opens a file in the editor ($VISUAL, $EDITOR or vi), through the system shell so the editor
+// command can carry arguments (e.g. "code --wait").
+func runEditor(editor, filePath string) error {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor == "" {
+			editor = os.Getenv(name)
+		}
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		if editor == "" {
+			editor = "notepad"
+		}
+		cmd = exec.Command("cmd", "/C", editor+` "`+filePath+`"`)
+	} else {
+		if editor == "" {
+			editor = "vi"
+		}
+		cmd = exec.Command("sh", "-c", editor+` "$1"`, "sh", filePath)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// confirmPrompt asks a yes/no question on the terminal, defaulting to no.
+func confirmPrompt(question string) bool {
+	fmt.Print(question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func importCommand() *cobra.Command {
+	var markdownSource, composerTargetPath string
+	var valueSets []string
+	var strict, hooks, dryRun bool
+
+	var importCmd = &cobra.Command{
+		Use: "import",
+		Annotations: GetDescriptions([]string{
+			"Reconstruct a project from a Markdown document with a tree view and path-labelled code fences",
+			"This command parses the tree view of a Markdown document and composes its files with the code fences labelled with their paths (title=\"cmd/main.go\" or a **cmd/main.go** line before the fence). The hooks of the document only run when asked, as it may come from anywhere",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if markdownSource == "" || composerTargetPath == "" {
+				gl.Log("error", "Both the Markdown source and the composer target path are required")
+				return
+			}
+			values, valuesErr := t.LoadTreeValues(valueSets, "", "")
+			if valuesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load tree values: %s", valuesErr))
+				return
+			}
+			ft, report, importErr := t.ImportMarkdownTree(markdownSource, composerTargetPath, &t.FileTreeOptions{Values: values})
+			if importErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to import markdown: %s", importErr))
+				return
+			}
+			for _, fence := range report.UnmatchedFences {
+				gl.Log("warn", fmt.Sprintf("Fence at line %d labelled '%s' matches no file of the tree", fence.Line, fence.Path))
+			}
+			for _, filePath := range report.FilesWithoutBody {
+				gl.Log("warn", fmt.Sprintf("File '%s' got no content from the document", filePath))
+			}
+			gl.Log("info", fmt.Sprintf("Matched %d fences, %d unmatched, %d files without content", report.Matched, len(report.UnmatchedFences), len(report.FilesWithoutBody)))
+			if strict && (len(report.UnmatchedFences) > 0 || len(report.FilesWithoutBody) > 0) {
+				gl.Log("error", "Import is incomplete, nothing was composed")
+				return
+			}
+			// Documentos importados são de terceiros: os hooks só rodam quando pedidos
+			tc, tcErr := t.NewTreeComposerWithOptions(ft, &t.TreeComposerOptions{NoHooks: !hooks, DryRun: dryRun})
+			if tcErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to create tree composer: %s", tcErr))
+				return
+			}
+			if err := tc.MakeTree(); err != nil {
+				gl.Log("error", fmt.Sprintf("Failed to compose tree: %s", err))
+				return
+			}
+			if dryRun {
+				gl.Log("success", "Dry run finished, nothing was composed")
+				return
+			}
+			gl.Log("success", fmt.Sprintf("Tree composed at %s", composerTargetPath))
+		},
+	}
+
+	importCmd.Flags().StringVarP(&markdownSource, "source", "s", "", "Path to the Markdown document")
+	importCmd.Flags().StringVarP(&composerTargetPath, "composer", "c", "", "Path to the composer target directory")
+	importCmd.Flags().StringArrayVar(&valueSets, "set", []string{}, "Set a template variable (k=v), can be repeated")
+	importCmd.Flags().BoolVar(&strict, "strict", false, "Compose nothing when a fence or a file is left unmatched")
+	importCmd.Flags().BoolVar(&hooks, "hooks", false, "Run the @hook lines and run: annotations of the document after composing")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only log the entries and hooks that would be composed and run")
+
+	return importCmd
+}
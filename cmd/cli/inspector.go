@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func inspectCommand() *cobra.Command {
+	var source, outputPath string
+	var ignore []string
+	var stripComponents, maxDepth int
+	var sizes, modes, comments bool
+
+	var inspectCmd = &cobra.Command{
+		Use: "inspect",
+		Annotations: GetDescriptions([]string{
+			"Render the tree view of an archive, a directory or a tree file",
+			"This command reads a .tar, .tar.gz, .tgz or .zip archive (without extracting it), a directory or a tree view file, and renders it as a tree view that can be parsed back",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if source == "" && len(args) > 0 {
+				source = args[0]
+			}
+			if source == "" {
+				gl.Log("error", "The source to inspect is required")
+				return
+			}
+			ft, loadErr := loadInspectedTree(source, ignore, stripComponents, nil)
+			if loadErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to read '%s': %s", source, loadErr))
+				return
+			}
+			view := t.RenderTreeView(ft, &t.TreeRenderOptions{Comments: comments, Sizes: sizes, Modes: modes, MaxDepth: maxDepth})
+			if outputPath != "" {
+				if err := os.WriteFile(outputPath, []byte(view), 0644); err != nil {
+					gl.Log("error", fmt.Sprintf("Failed to write tree view: %s", err))
+					return
+				}
+				gl.Log("success", fmt.Sprintf("Tree view written to %s", outputPath))
+			} else {
+				fmt.Print(view)
+			}
+			counts := make(map[string]int)
+			for _, entry := range ft.GetEntries() {
+				counts[entry.GetType()]++
+			}
+			gl.Log("info", fmt.Sprintf("%d directories, %d files, %d symlinks", counts["directory"], counts["file"], counts["symlink"]))
+		},
+	}
+
+	inspectCmd.Flags().StringVarP(&source, "source", "s", "", "Path to the archive, directory or tree view file")
+	inspectCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Write the tree view to a file instead of the standard output")
+	inspectCmd.Flags().StringArrayVar(&ignore, "ignore", []string{}, "Leave out the paths or names matching a glob, can be repeated")
+	inspectCmd.Flags().IntVar(&stripComponents, "strip-components", 0, "Remove leading path components of the archive members")
+	inspectCmd.Flags().IntVar(&maxDepth, "depth", 0, "Render only the entries up to this depth (0 renders all)")
+	inspectCmd.Flags().BoolVar(&sizes, "sizes", false, "Show the size of the files")
+	inspectCmd.Flags().BoolVar(&modes, "modes", false, "Show the permissions of the entries")
+	inspectCmd.Flags().BoolVar(&comments, "comments", true, "Show the comments and annotations of the entries")
+
+	return inspectCmd
+}
+
+// loadInspectedTree reads a tree from an archive (by the extension), a directory or a tree view file, parsed
+// with the given options.
+func loadInspectedTree(source string, ignore []string, stripComponents int, treeOptions *t.FileTreeOptions) (it.IFileTree, error) {
+	if _, formatErr := t.ArchiveFormat(source); formatErr == nil {
+		return t.ReadArchiveTree(source, &t.ArchiveTreeOptions{Ignore: ignore, StripComponents: stripComponents})
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return t.ScanDirectoryTree(source, &t.DirectoryScanOptions{Ignore: ignore})
+	}
+	return t.NewFileTreeWithOptions(source, ".", false, nil, false, treeOptions)
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func undoCommand() *cobra.Command {
+	var composerTargetPath string
+	var force, dryRun bool
+
+	var undoCmd = &cobra.Command{
+		Use: "undo",
+		Annotations: GetDescriptions([]string{
+			"Undo the last composition of a target directory",
+			"This command removes the paths created by the last composition recorded in the journal of the target directory (.cleandgo/journal.json), in reverse order and only when unchanged since, leaving the files of the user alone",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if composerTargetPath == "" && len(args) > 0 {
+				composerTargetPath = args[0]
+			}
+			if composerTargetPath == "" {
+				gl.Log("error", "The composer target path is required")
+				return
+			}
+			report, undoErr := t.UndoTreeJournal(composerTargetPath, &t.JournalUndoOptions{Force: force, DryRun: dryRun})
+			if undoErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to undo the composition: %s", undoErr))
+				return
+			}
+			prefix := ""
+			if dryRun {
+				prefix = "[dry-run] "
+			}
+			for _, removed := range report.Removed {
+				gl.Log("info", fmt.Sprintf("%sremove %s", prefix, removed))
+			}
+			kept := make([]string, 0, len(report.Kept))
+			for keptPath := range report.Kept {
+				kept = append(kept, keptPath)
+			}
+			sort.Strings(kept)
+			for _, keptPath := range kept {
+				gl.Log("warn", fmt.Sprintf("%skeep %s: %s", prefix, keptPath, report.Kept[keptPath]))
+			}
+			gl.Log("success", fmt.Sprintf("%sUndo of %s: %d removed, %d kept, %d already missing", prefix, report.Generation, len(report.Removed), len(report.Kept), len(report.Missing)))
+		},
+	}
+
+	undoCmd.Flags().StringVarP(&composerTargetPath, "composer", "c", "", "Path to the composer target directory")
+	undoCmd.Flags().BoolVar(&force, "force", false, "Remove the files and symlinks changed since the composition too")
+	undoCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show what would be removed")
+
+	return undoCmd
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func mergeCommand() *cobra.Command {
+	var outputPath, format string
+
+	var mergeCmd = &cobra.Command{
+		Use: "merge",
+		Annotations: GetDescriptions([]string{
+			"Three-way merge of tree view files",
+			"This command merges the changes made to a base tree view file (the first argument) by two edited versions (ours and theirs). Additions, removals, moves, renames and comment or metadata changes made by a single side merge on their own, and the entries changed differently by both sides are drawn between <<<<<<< ours, ======= and >>>>>>> theirs lines. The files are merged as written, keeping their templates, conditions, rules and hooks. It exits with 1 when conflicts are left and 2 when the merge failed",
+		}, false),
+		Version: vs.GetVersion(),
+		Args:    cobra.ExactArgs(3),
+		Run: func(cmd *cobra.Command, args []string) {
+			trees := make([]it.IFileTree, 0, 3)
+			for _, source := range args {
+				ft, loadErr := loadMergedTree(source)
+				if loadErr != nil {
+					gl.Log("error", fmt.Sprintf("Failed to read '%s': %s", source, loadErr))
+					os.Exit(2)
+				}
+				trees = append(trees, ft)
+			}
+			merge, mergeErr := t.MergeTrees(trees[0], trees[1], trees[2])
+			if mergeErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to merge the trees: %s", mergeErr))
+				os.Exit(2)
+			}
+			var output string
+			switch format {
+			case "json":
+				data, err := json.MarshalIndent(merge.Conflicts, "", "  ")
+				if err != nil {
+					gl.Log("error", fmt.Sprintf("Failed to encode the conflicts: %s", err))
+					os.Exit(2)
+				}
+				output = string(data) + "\n"
+			case "text":
+				output = merge.Render()
+			default:
+				gl.Log("error", fmt.Sprintf("Unknown format '%s', use text or json", format))
+				os.Exit(2)
+			}
+			if outputPath != "" {
+				if err := os.WriteFile(outputPath, []byte(output), 0644); err != nil {
+					gl.Log("error", fmt.Sprintf("Failed to write the merged tree: %s", err))
+					os.Exit(2)
+				}
+			} else {
+				fmt.Print(output)
+			}
+			for _, conflict := range merge.Conflicts {
+				gl.Log("warn", fmt.Sprintf("Conflict (%s) at %s: ours %s, theirs %s", conflict.Kind, conflict.Path, conflict.Ours, conflict.Theirs))
+			}
+			if merge.HasConflicts() {
+				os.Exit(1)
+			}
+		},
+	}
+
+	mergeCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Write the merged tree view to a file instead of the standard output")
+	mergeCmd.Flags().StringVar(&format, "format", "text", "Output format: text (the merged tree view) or json (the conflicts)")
+
+	return mergeCmd
+}
+
+// loadMergedTree reads a side of a merge: tree files as written (see types.LoadTreeSource), directories and
+// archives as scanned.
+func loadMergedTree(source string) (it.IFileTree, error) {
+	if info, err := os.Stat(source); err == nil && !info.IsDir() {
+		if _, formatErr := t.ArchiveFormat(source); formatErr != nil {
+			return t.LoadTreeSource(source)
+		}
+	}
+	return loadInspectedTree(source, nil, 0, nil)
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	it "github.com/faelmori/cleandgo/interfaces"
 	gl "github.com/faelmori/cleandgo/logger"
 	t "github.com/faelmori/cleandgo/types"
 	vs "github.com/faelmori/cleandgo/version"
@@ -13,13 +14,25 @@ import (
 func ParserCmdList() []*cobra.Command {
 	return []*cobra.Command{
 		parseCommand(),
+		importCommand(),
+		inspectCommand(),
+		undoCommand(),
+		editCommand(),
+		diffCommand(),
+		mergeCommand(),
+		upgradeCommand(),
+		queryCommand(),
 	}
 }
 
 func parseCommand() *cobra.Command {
 	var treeFileSource, composerTargetPath string
 	var printTree bool
-	var debug, onlyDirectories, onlyFiles, quiet bool
+	var debug, onlyDirectories, onlyFiles, quiet, applyOwnership, noSkeletons, keepModes, noHooks, dryRun, noJournal, lock, syncTarget, prune bool
+	var skeletonDir, placeholder, archivePath, quarantineDir string
+	var valueSets []string
+	var envFile, valuesFile string
+	var features, profiles, overlays, ruleFiles, templatePacks, syncIgnore []string
 
 	var parseCmd = &cobra.Command{
 		Use: "parse",
@@ -29,27 +42,187 @@ func parseCommand() *cobra.Command {
 		}, false),
 		Version: vs.GetVersion(),
 		Run: func(cmd *cobra.Command, args []string) {
-			ft, ftErr := t.NewFileTree(treeFileSource, composerTargetPath, printTree, nil, debug)
-			if ftErr != nil {
-				gl.Log("error", fmt.Sprintf("Failed to create file tree: %s", ftErr))
+			values, valuesErr := t.LoadTreeValues(valueSets, envFile, valuesFile)
+			if valuesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load tree values: %s", valuesErr))
+				return
+			}
+			// Profiles add features and default values, the explicit values always win
+			var profileErr error
+			if features, profileErr = applyTreeProfiles(profiles, values, features); profileErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load profile: %s", profileErr))
 				return
 			}
-			if err := ft.ParseTree(); err != nil {
-				gl.Log("error", fmt.Sprintf("Failed to parse tree: %s", err))
+			rules, rulesErr := loadTreeRuleFiles(ruleFiles)
+			if rulesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load rules: %s", rulesErr))
+				return
+			}
+			// Archives are written without touching the composer target directory
+			treeTargetPath := composerTargetPath
+			if treeTargetPath == "" && archivePath != "" {
+				treeTargetPath = "."
+			}
+			// NewFileTreeWithOptions already parses the tree source
+			ft, ftErr := t.NewFileTreeWithOptions(treeFileSource, treeTargetPath, printTree, nil, debug, &t.FileTreeOptions{
+				Values:    values,
+				Features:  features,
+				Overlays:  overlays,
+				Rules:     rules,
+				RuleFiles: ruleFiles,
+				Profiles:  profiles,
+			})
+			if ftErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to create file tree: %s", ftErr))
 				return
 			}
 			gl.Log("success", "Tree parsed successfully!!!")
+			if composerTargetPath != "" || archivePath != "" {
+				var skeletons map[string]string
+				if skeletonDir != "" {
+					var skeletonsErr error
+					if skeletons, skeletonsErr = t.LoadSkeletonDir(skeletonDir); skeletonsErr != nil {
+						gl.Log("error", fmt.Sprintf("Failed to load skeletons: %s", skeletonsErr))
+						return
+					}
+				}
+				packs := make([]*t.TemplatePack, 0, len(templatePacks))
+				for _, packDir := range templatePacks {
+					pack, packErr := t.LoadTemplatePackDir(packDir)
+					if packErr != nil {
+						gl.Log("error", fmt.Sprintf("Failed to load template pack: %s", packErr))
+						return
+					}
+					packs = append(packs, pack)
+				}
+				tc, tcErr := t.NewTreeComposerWithOptions(ft, &t.TreeComposerOptions{
+					ApplyOwnership: applyOwnership,
+					NoSkeletons:    noSkeletons,
+					PreserveModes:  keepModes,
+					Placeholder:    placeholder,
+					NoHooks:        noHooks,
+					NoJournal:      noJournal,
+					Lock:           lock,
+					DryRun:         dryRun,
+					Skeletons:      skeletons,
+					TemplatePacks:  packs,
+				})
+				if tcErr != nil {
+					gl.Log("error", fmt.Sprintf("Failed to create tree composer: %s", tcErr))
+					return
+				}
+				var composeErr error
+				if archivePath != "" {
+					composeErr = tc.MakeTreeArchive(archivePath)
+				} else if syncTarget {
+					composeErr = syncComposedTree(tc, &t.TreeSyncOptions{Prune: prune, QuarantineDir: quarantineDir, Ignore: syncIgnore, DryRun: dryRun})
+				} else {
+					composeErr = tc.MakeTree()
+				}
+				if composer, ok := tc.(*t.TreeComposer); ok {
+					for _, result := range composer.HookResults {
+						if !result.Skipped {
+							gl.Log("info", fmt.Sprintf("%s $ %s (exit %d)\n%s", result.Dir, result.Command, result.ExitCode, result.Output))
+						}
+					}
+				}
+				if composeErr != nil {
+					gl.Log("error", fmt.Sprintf("Failed to compose tree: %s", composeErr))
+					return
+				}
+				if dryRun {
+					gl.Log("success", "Dry run finished, nothing was composed")
+				} else if archivePath != "" {
+					gl.Log("success", fmt.Sprintf("Tree archived at %s", archivePath))
+				} else {
+					gl.Log("success", fmt.Sprintf("Tree composed at %s", composerTargetPath))
+				}
+			}
 			gl.Log("info", "See you later...")
 		},
 	}
 
 	parseCmd.Flags().StringVarP(&treeFileSource, "source", "s", "", "Path to the tree view file")
 	parseCmd.Flags().StringVarP(&composerTargetPath, "composer", "c", "", "Path to the composer target directory")
+	parseCmd.Flags().StringVar(&archivePath, "archive", "", "Compose into an archive (.tar, .tar.gz, .tgz or .zip) instead of the composer directory")
 	parseCmd.Flags().BoolVarP(&printTree, "print", "p", false, "Print the tree view")
 	parseCmd.Flags().BoolVarP(&onlyDirectories, "onlyDirectories", "D", false, "Only include directories in the output")
 	parseCmd.Flags().BoolVarP(&onlyFiles, "onlyFiles", "F", false, "Only include files in the output")
 	parseCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug mode")
 	parseCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress output messages")
+	parseCmd.Flags().BoolVar(&applyOwnership, "chown", false, "Apply the owner/group annotations to the composed entries")
+	parseCmd.Flags().StringVar(&skeletonDir, "skeletons", "", "Path to a directory with <ext>.tmpl skeletons overriding the built-in ones")
+	parseCmd.Flags().StringArrayVar(&templatePacks, "templates", []string{}, "Path to a template pack directory rendering the file contents, can be repeated")
+	parseCmd.Flags().BoolVar(&noSkeletons, "no-skeletons", false, "Create the new files empty, without starter content")
+	parseCmd.Flags().BoolVar(&keepModes, "keep-modes", false, "Keep the permissions of the from= sources in the copied entries")
+	parseCmd.Flags().StringVar(&placeholder, "placeholder", "", "Placeholder file dropped in empty directories (e.g. .gitkeep, or README.md generated from the comment)")
+	parseCmd.Flags().BoolVar(&noHooks, "no-hooks", false, "Do not run the @hook lines and run: annotations after composing")
+	parseCmd.Flags().BoolVar(&noJournal, "no-journal", false, "Do not record the created paths in the journal used by cleandgo undo")
+	parseCmd.Flags().BoolVar(&lock, "lock", false, "Record the generated contents in the lock used by cleandgo upgrade (always done with --templates)")
+	parseCmd.Flags().BoolVar(&syncTarget, "sync", false, "Make the composer directory match the tree: create, retype, relink and chmod entries")
+	parseCmd.Flags().BoolVar(&prune, "prune", false, "With --sync, delete (or quarantine) the paths that are not in the tree")
+	parseCmd.Flags().StringVar(&quarantineDir, "quarantine", "", "With --sync, move the pruned and retyped paths to this directory instead of deleting them")
+	parseCmd.Flags().Lookup("quarantine").NoOptDefVal = t.DefaultQuarantineDir
+	parseCmd.Flags().StringArrayVar(&syncIgnore, "ignore", []string{}, "With --sync, protect the paths or names matching a glob, can be repeated")
+	parseCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only log the entries that would be created and the hooks that would run")
+	parseCmd.Flags().StringArrayVar(&valueSets, "set", []string{}, "Set a template variable (k=v), can be repeated")
+	parseCmd.Flags().StringVar(&envFile, "env-file", "", "Path to an env file with template variables")
+	parseCmd.Flags().StringVar(&valuesFile, "values", "", "Path to a YAML file with template variables")
+	parseCmd.Flags().StringArrayVar(&features, "feature", []string{}, "Enable a feature for the # if:/# unless: entries, can be repeated")
+	parseCmd.Flags().StringArrayVar(&overlays, "overlay", []string{}, "Path to an overlay file applied on top of the tree, can be repeated")
+	parseCmd.Flags().StringArrayVar(&ruleFiles, "rules", []string{}, "Path to a file with @rule lines generating companion entries, can be repeated")
+	parseCmd.Flags().StringArrayVar(&profiles, "profile", []string{}, "Path to a profile file with features and values, can be repeated")
 
 	return parseCmd
 }
+
+// applyTreeProfiles loads the profiles, returning the features with theirs added and filling the values
+// missing from the given ones with the profile values.
+func applyTreeProfiles(profiles []string, values map[string]any, features []string) ([]string, error) {
+	for _, profilePath := range profiles {
+		profile, err := t.LoadTreeProfile(profilePath)
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, profile.Features...)
+		for k, v := range profile.Values {
+			if _, exists := values[k]; !exists {
+				values[k] = v
+			}
+		}
+	}
+	return features, nil
+}
+
+// loadTreeRuleFiles loads the @rule lines of the rule files, in order.
+func loadTreeRuleFiles(ruleFiles []string) ([]string, error) {
+	rules := make([]string, 0)
+	for _, rulesPath := range ruleFiles {
+		fileRules, err := t.LoadTreeRules(rulesPath)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	return rules, nil
+}
+
+// syncComposedTree syncs the composer directory with the tree, logging the actions and the extras left.
+func syncComposedTree(tc it.ITreeComposer, options *t.TreeSyncOptions) error {
+	composer, ok := tc.(*t.TreeComposer)
+	if !ok {
+		return fmt.Errorf("invalid tree composer type")
+	}
+	report, err := composer.SyncTree(options)
+	if report != nil {
+		if !options.DryRun {
+			for _, action := range report.Actions {
+				gl.Log("info", fmt.Sprintf("%s %s (%s)", action.Action, action.Path, action.Detail))
+			}
+		}
+		for _, extra := range report.Extras {
+			gl.Log("warn", fmt.Sprintf("Not in the tree: %s (use --prune to remove it)", extra))
+		}
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func queryCommand() *cobra.Command {
+	var source, format string
+	var valueSets, features, ignore []string
+	var valuesFile string
+	var stripComponents int
+	var comments bool
+
+	var queryCmd = &cobra.Command{
+		Use: "query",
+		Annotations: GetDescriptions([]string{
+			"Select the entries of a tree matching a query",
+			"This command reads a tree view file, a directory or an archive and lists the entries matching all the terms of the query, such as `type=file depth>2 ext=.java tag=api`. Terms select by path or name glob, type, depth, extension, size, comment text, tag and any metadata key. The matches are printed as paths, as the tree pruned to them or as JSON, and the command exits with 1 when nothing matches and 2 when the query failed",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if source == "" && len(args) > 0 {
+				source, args = args[0], args[1:]
+			}
+			if source == "" {
+				gl.Log("error", "The tree view file, directory or archive to query is required")
+				os.Exit(2)
+			}
+			query, queryErr := t.ParseTreeQuery(strings.Join(args, " "))
+			if queryErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to parse the query: %s", queryErr))
+				os.Exit(2)
+			}
+			values, valuesErr := t.LoadTreeValues(valueSets, "", valuesFile)
+			if valuesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load tree values: %s", valuesErr))
+				os.Exit(2)
+			}
+			ft, loadErr := loadInspectedTree(source, ignore, stripComponents, &t.FileTreeOptions{Values: values, Features: features})
+			if loadErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to read '%s': %s", source, loadErr))
+				os.Exit(2)
+			}
+			matches := t.QueryTree(ft, query)
+			switch format {
+			case "paths":
+				for _, entry := range matches {
+					fmt.Println(entry.GetPath())
+				}
+			case "tree":
+				paths := make([]string, 0, len(matches))
+				for _, entry := range matches {
+					paths = append(paths, entry.GetPath())
+				}
+				if len(paths) > 0 {
+					fmt.Print(t.RenderTreeView(ft, &t.TreeRenderOptions{Comments: comments, Paths: paths}))
+				}
+			case "json":
+				results := make([]t.TreeQueryMatch, 0, len(matches))
+				for _, entry := range matches {
+					results = append(results, t.NewTreeQueryMatch(entry))
+				}
+				data, err := json.MarshalIndent(results, "", "  ")
+				if err != nil {
+					gl.Log("error", fmt.Sprintf("Failed to encode the matches: %s", err))
+					os.Exit(2)
+				}
+				fmt.Println(string(data))
+			default:
+				gl.Log("error", fmt.Sprintf("Unknown format '%s', use paths, tree or json", format))
+				os.Exit(2)
+			}
+			gl.Log("info", fmt.Sprintf("%d of %d entries match", len(matches), len(ft.GetEntries())))
+			if len(matches) == 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	queryCmd.Flags().StringVarP(&source, "source", "s", "", "Path to the tree view file, directory or archive (or the first argument)")
+	queryCmd.Flags().StringVar(&format, "format", "paths", "Output format: paths, tree (pruned to the matches) or json")
+	queryCmd.Flags().BoolVar(&comments, "comments", true, "With --format tree, show the comments and annotations of the entries")
+	queryCmd.Flags().StringArrayVar(&ignore, "ignore", []string{}, "Leave out the paths or names matching a glob, can be repeated")
+	queryCmd.Flags().IntVar(&stripComponents, "strip-components", 0, "Remove leading path components of the archive members")
+	queryCmd.Flags().StringArrayVar(&valueSets, "set", []string{}, "Set a template variable (k=v), can be repeated")
+	queryCmd.Flags().StringVar(&valuesFile, "values", "", "Path to a YAML file with template variables")
+	queryCmd.Flags().StringArrayVar(&features, "feature", []string{}, "Enable a feature for the # if:/# unless: entries, can be repeated")
+
+	return queryCmd
+}
@@ -0,0 +1,193 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	t "github.com/faelmori/cleandgo/types"
+	utl "github.com/faelmori/cleandgo/utils"
+	vs "github.com/faelmori/cleandgo/version"
+)
+
+func upgradeCommand() *cobra.Command {
+	var treeFileSource, composerTargetPath, skeletonDir, placeholder string
+	var valueSets, features, templatePacks, overlays, ruleFiles, profiles []string
+	var envFile, valuesFile string
+	var noSkeletons, noHooks, dryRun bool
+
+	var upgradeCmd = &cobra.Command{
+		Use: "upgrade",
+		Annotations: GetDescriptions([]string{
+			"Upgrade a composed directory to a newer template, keeping the local edits",
+			"This command re-renders the tree, the template packs, the overlays, the rule files and the profiles recorded in the lock of a composed directory (or the ones given), with the recorded variables overridden by the given ones. The files never touched since their generation are updated, the files edited locally are merged line by line with the new content and the overlapping changes are left between <<<<<<< local, ======= and >>>>>>> template lines. It exits with 1 when conflicts are left and 2 when the upgrade failed",
+		}, false),
+		Version: vs.GetVersion(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if composerTargetPath == "" {
+				gl.Log("error", "The composer target directory is required")
+				os.Exit(2)
+			}
+			lock, lockErr := t.LoadTreeLock(utl.NewOsFileSystem(), composerTargetPath)
+			if lockErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load the lock: %s", lockErr))
+				os.Exit(2)
+			}
+			if treeFileSource == "" {
+				treeFileSource = lock.TreeFile
+			}
+			if treeFileSource == "" {
+				gl.Log("error", fmt.Sprintf("No tree view file given nor locked in '%s'", composerTargetPath))
+				os.Exit(2)
+			}
+			values, valuesErr := t.LoadTreeValues(valueSets, envFile, valuesFile)
+			if valuesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load tree values: %s", valuesErr))
+				os.Exit(2)
+			}
+			// Os valores dados ganham dos gravados no lock
+			for k, v := range lock.Values {
+				if _, exists := values[k]; !exists {
+					values[k] = v
+				}
+			}
+			if !cmd.Flags().Changed("feature") {
+				features = lock.Features
+			}
+			if !cmd.Flags().Changed("templates") {
+				for _, template := range lock.Templates {
+					templatePacks = append(templatePacks, template.Source)
+				}
+			}
+			// Overlays, regras e perfis da composição geram entradas que não podem sumir na atualização
+			if !cmd.Flags().Changed("overlay") {
+				overlays = lock.Overlays
+			}
+			if !cmd.Flags().Changed("rules") {
+				ruleFiles = lock.RuleFiles
+			}
+			if !cmd.Flags().Changed("profile") {
+				profiles = lock.Profiles
+			}
+			var profileErr error
+			if features, profileErr = applyTreeProfiles(profiles, values, features); profileErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load profile: %s", profileErr))
+				os.Exit(2)
+			}
+			rules, rulesErr := loadTreeRuleFiles(ruleFiles)
+			if rulesErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to load rules: %s", rulesErr))
+				os.Exit(2)
+			}
+			ft, ftErr := t.NewFileTreeWithOptions(treeFileSource, composerTargetPath, false, nil, false, &t.FileTreeOptions{
+				Values:    values,
+				Features:  features,
+				Overlays:  overlays,
+				Rules:     rules,
+				RuleFiles: ruleFiles,
+				Profiles:  profiles,
+			})
+			if ftErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to parse tree: %s", ftErr))
+				os.Exit(2)
+			}
+			var skeletons map[string]string
+			if skeletonDir != "" {
+				var skeletonsErr error
+				if skeletons, skeletonsErr = t.LoadSkeletonDir(skeletonDir); skeletonsErr != nil {
+					gl.Log("error", fmt.Sprintf("Failed to load skeletons: %s", skeletonsErr))
+					os.Exit(2)
+				}
+			}
+			packs := make([]*t.TemplatePack, 0, len(templatePacks))
+			versions := make([]string, 0, len(templatePacks))
+			for _, packDir := range templatePacks {
+				pack, packErr := t.LoadTemplatePackDir(packDir)
+				if packErr != nil {
+					gl.Log("error", fmt.Sprintf("Failed to load template pack: %s", packErr))
+					os.Exit(2)
+				}
+				packs = append(packs, pack)
+				versions = append(versions, upgradePackVersion(lock, pack))
+			}
+			if len(versions) > 0 {
+				gl.Log("info", fmt.Sprintf("Templates: %s", strings.Join(versions, ", ")))
+			}
+			tc, tcErr := t.NewTreeComposerWithOptions(ft, &t.TreeComposerOptions{
+				NoSkeletons:   noSkeletons,
+				Placeholder:   placeholder,
+				NoHooks:       noHooks,
+				Skeletons:     skeletons,
+				TemplatePacks: packs,
+			})
+			if tcErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to create tree composer: %s", tcErr))
+				os.Exit(2)
+			}
+			composer := tc.(*t.TreeComposer)
+			report, upgradeErr := composer.UpgradeTree(&t.TreeUpgradeOptions{DryRun: dryRun})
+			if report != nil && !dryRun {
+				for _, action := range report.Actions {
+					gl.Log("info", fmt.Sprintf("%s %s (%s)", action.Action, action.Path, action.Detail))
+				}
+			}
+			for _, result := range composer.HookResults {
+				if !result.Skipped {
+					gl.Log("info", fmt.Sprintf("%s $ %s (exit %d)\n%s", result.Dir, result.Command, result.ExitCode, result.Output))
+				}
+			}
+			if upgradeErr != nil {
+				gl.Log("error", fmt.Sprintf("Failed to upgrade '%s': %s", composerTargetPath, upgradeErr))
+				os.Exit(2)
+			}
+			if report.HasConflicts() {
+				for _, conflict := range report.Conflicts {
+					gl.Log("warn", fmt.Sprintf("Conflict in %s", conflict))
+				}
+				os.Exit(1)
+			}
+			if dryRun {
+				gl.Log("success", "Dry run finished, nothing was upgraded")
+			} else {
+				gl.Log("success", fmt.Sprintf("Tree upgraded at %s", composerTargetPath))
+			}
+		},
+	}
+
+	upgradeCmd.Flags().StringVarP(&treeFileSource, "source", "s", "", "Path to the tree view file (the locked one when empty)")
+	upgradeCmd.Flags().StringVarP(&composerTargetPath, "composer", "c", "", "Path to the composed directory to upgrade")
+	upgradeCmd.Flags().StringArrayVar(&templatePacks, "templates", []string{}, "Path to a template pack directory (the locked ones when not given), can be repeated")
+	upgradeCmd.Flags().StringVar(&skeletonDir, "skeletons", "", "Path to a directory with <ext>.tmpl skeletons overriding the built-in ones")
+	upgradeCmd.Flags().BoolVar(&noSkeletons, "no-skeletons", false, "Create the new files empty, without starter content")
+	upgradeCmd.Flags().StringVar(&placeholder, "placeholder", "", "Placeholder file dropped in empty directories (e.g. .gitkeep, or README.md generated from the comment)")
+	upgradeCmd.Flags().BoolVar(&noHooks, "no-hooks", false, "Do not run the @hook lines and run: annotations of the created entries")
+	upgradeCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only log what would be updated, merged, created and removed")
+	upgradeCmd.Flags().StringArrayVar(&valueSets, "set", []string{}, "Set a template variable (k=v) over the locked ones, can be repeated")
+	upgradeCmd.Flags().StringVar(&envFile, "env-file", "", "Path to an env file with template variables")
+	upgradeCmd.Flags().StringVar(&valuesFile, "values", "", "Path to a YAML file with template variables")
+	upgradeCmd.Flags().StringArrayVar(&overlays, "overlay", []string{}, "Path to an overlay file applied on top of the tree (the locked ones when not given), can be repeated")
+	upgradeCmd.Flags().StringArrayVar(&ruleFiles, "rules", []string{}, "Path to a file with @rule lines (the locked ones when not given), can be repeated")
+	upgradeCmd.Flags().StringArrayVar(&profiles, "profile", []string{}, "Path to a profile file with features and values (the locked ones when not given), can be repeated")
+	upgradeCmd.Flags().StringArrayVar(&features, "feature", []string{}, "Enable a feature for the # if:/# unless: entries (the locked ones when not given), can be repeated")
+
+	return upgradeCmd
+}
+
+// upgradePackVersion describes a template pack, with the version change since the lock when there is one.
+func upgradePackVersion(lock *t.TreeLock, pack *t.TemplatePack) string {
+	version := pack.Manifest.Version
+	if version == "" {
+		version = "unversioned"
+	}
+	source, _ := filepath.Abs(pack.Source)
+	for _, locked := range lock.Templates {
+		if locked.Source == source && locked.Version != pack.Manifest.Version && locked.Version != "" {
+			return fmt.Sprintf("%s %s -> %s", pack.Source, locked.Version, version)
+		}
+	}
+	return fmt.Sprintf("%s %s", pack.Source, version)
+}
@@ -5,7 +5,11 @@ type ITreeComposer interface {
 	MakeTreeFiles() error
 	MakeTreeSymlinks() error
 	MakeTree() error
+	MakeTreeArchive(archivePath string) error
 	SetFilePermissions(path, permissions string) error
 	EnsureTreePermissions() error
+	EnsureTreeOwnership() error
+	EnsureTreePlaceholders() error
 	EnsureTreeChecksums() error
+	RunTreeHooks() error
 }
@@ -8,12 +8,17 @@ type IFileTree interface {
 	GetDrawedMap() map[string]string
 	GetEntries() []IFileEntry
 	GetEntryByName(name string) IFileEntry
+	GetEntryByPath(entryPath string) IFileEntry
 	AddEntry(entry IFileEntry)
+	AddEntryByPath(entryPath, entryType, comments string) (IFileEntry, error)
+	RemoveEntry(id uuid.UUID) error
+	MoveEntry(id uuid.UUID, newPath string) error
 	SetEntriesDepth()
 	GetEntryByID(id uuid.UUID) IFileEntry
 	GetChildren(parentID uuid.UUID) []IFileEntry
 	Sanitize(dirtyData []byte) error
 	ParseTree() error
+	ParseTreeText(source, text string) error
 	SerializeToFile(format string) error
 	LoadFromFile(format string) error
 	BackupTreeFile() error
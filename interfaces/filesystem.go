@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IFileSystem is the filesystem used to compose, scan and back up trees, so they can target the disk, memory
+// or a directory jail alike.
+type IFileSystem interface {
+	Name() string
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	ReadDir(name string) ([]os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Walk(root string, fn filepath.WalkFunc) error
+}
@@ -0,0 +1,321 @@
+package types
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// ArchiveFormat returns the archive format of a path by its extension: "tar", "tar.gz" or "zip".
+func ArchiveFormat(archivePath string) (string, error) {
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz", nil
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar", nil
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip", nil
+	}
+	return "", fmt.Errorf("unsupported archive format '%s', expected .tar, .tar.gz, .tgz or .zip", archivePath)
+}
+
+// archiveWriter adds the composed entries to an archive.
+type archiveWriter interface {
+	addDir(name string, mode os.FileMode, modTime time.Time) error
+	addFile(name string, mode os.FileMode, modTime time.Time, data []byte, owner, group string) error
+	addSymlink(name, target string, modTime time.Time) error
+	Close() error
+}
+
+// tarArchiveWriter writes tar archives, optionally compressed with gzip.
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func newTarArchiveWriter(w io.Writer, compress bool) *tarArchiveWriter {
+	if compress {
+		gz := gzip.NewWriter(w)
+		return &tarArchiveWriter{tw: tar.NewWriter(gz), gz: gz}
+	}
+	return &tarArchiveWriter{tw: tar.NewWriter(w)}
+}
+
+func (a *tarArchiveWriter) addDir(name string, mode os.FileMode, modTime time.Time) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: tarMode(mode), ModTime: modTime})
+}
+
+func (a *tarArchiveWriter) addFile(name string, mode os.FileMode, modTime time.Time, data []byte, owner, group string) error {
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: tarMode(mode), ModTime: modTime, Size: int64(len(data)), Uname: owner, Gname: group}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(data)
+	return err
+}
+
+func (a *tarArchiveWriter) addSymlink(name, target string, modTime time.Time) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: target, Mode: 0o777, ModTime: modTime})
+}
+
+func (a *tarArchiveWriter) Close() error {
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+// tarMode converts a file mode to the unix mode bits of a tar header.
+func tarMode(mode os.FileMode) int64 {
+	bits := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+// zipArchiveWriter writes zip archives, keeping the unix modes in the external attributes.
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiveWriter) addDir(name string, mode os.FileMode, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name + "/", Modified: modTime}
+	header.SetMode(mode | os.ModeDir)
+	_, err := a.zw.CreateHeader(header)
+	return err
+}
+
+func (a *zipArchiveWriter) addFile(name string, mode os.FileMode, modTime time.Time, data []byte, _, _ string) error {
+	header := &zip.FileHeader{Name: name, Modified: modTime, Method: zip.Deflate}
+	header.SetMode(mode)
+	w, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (a *zipArchiveWriter) addSymlink(name, target string, modTime time.Time) error {
+	header := &zip.FileHeader{Name: name, Modified: modTime}
+	header.SetMode(os.ModeSymlink | 0o777)
+	w, err := a.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(target))
+	return err
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+// MakeTreeArchive composes the tree into an archive file (tar, tar.gz or zip, by the extension) instead of
+// the composer target directory.
+func (tc *TreeComposer) MakeTreeArchive(archivePath string) error {
+	format, err := ArchiveFormat(archivePath)
+	if err != nil {
+		return err
+	}
+	if tc.Options.DryRun {
+		gl.Log("info", fmt.Sprintf("[dry-run] write %d entries to %s", len(tc.FileTree.GetEntries()), archivePath))
+		return nil
+	}
+	fsys := tc.Options.FileSystem()
+	file, err := fsys.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive '%s': %w", archivePath, err)
+	}
+	if err := tc.WriteTreeArchive(file, format); err != nil {
+		file.Close()
+		_ = fsys.Remove(archivePath)
+		return err
+	}
+	return file.Close()
+}
+
+// WriteTreeArchive writes the composed tree to an archive stream. Entries keep their modes, modification
+// times and contents (inline bodies, `from=` sources, template packs and skeletons). Hooks are not run.
+func (tc *TreeComposer) WriteTreeArchive(w io.Writer, format string) error {
+	var archive archiveWriter
+	switch format {
+	case "tar":
+		archive = newTarArchiveWriter(w, false)
+	case "tar.gz", "tgz":
+		archive = newTarArchiveWriter(w, true)
+	case "zip":
+		archive = &zipArchiveWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unsupported archive format '%s'", format)
+	}
+	if len(tc.FileTree.Hooks) > 0 && !tc.Options.NoHooks {
+		gl.Log("warn", "Hooks are not run when composing into an archive")
+	}
+
+	now := time.Now()
+	written := make(map[string]bool)
+	for _, entry := range tc.FileTree.GetEntries() {
+		name := entry.GetPath()
+		if entryPathEscapes(name) {
+			gl.Log("error", fmt.Sprintf("Entry '%s' is outside the archive root", name))
+			return fmt.Errorf("entry '%s' is outside the archive root", name)
+		}
+		if written[name] {
+			continue // Já incluído pela cópia de um diretório `from=`
+		}
+		modTime := now
+		if modifiedAt := entry.GetModifiedAt(); modifiedAt != nil {
+			modTime = *modifiedAt
+		}
+		mode, err := tc.archiveMode(entry)
+		if err != nil {
+			return err
+		}
+		// Nomes com barras (ex: "src/main/java") precisam dos diretórios intermediários
+		parents := make([]string, 0)
+		for dir := path.Dir(name); dir != "." && !written[dir]; dir = path.Dir(dir) {
+			parents = append([]string{dir}, parents...)
+		}
+		for _, dir := range parents {
+			if err := archive.addDir(dir, 0o755, modTime); err != nil {
+				return fmt.Errorf("failed to add directory '%s' to the archive: %w", dir, err)
+			}
+			written[dir] = true
+		}
+		written[name] = true
+
+		switch entry.GetType() {
+		case "directory":
+			if err := archive.addDir(name, mode, modTime); err != nil {
+				return fmt.Errorf("failed to add directory '%s' to the archive: %w", name, err)
+			}
+			source, err := EntrySourcePath(tc.Options.SourceFileSystem(), tc.FileTree, entry)
+			if err != nil {
+				return err
+			}
+			if source != "" {
+				if err := tc.archiveSourceDir(archive, source, name, written); err != nil {
+					return err
+				}
+			} else if tc.Options.Placeholder != "" && len(tc.FileTree.GetChildren(entry.GetID())) == 0 {
+				placeholder := path.Join(name, tc.Options.Placeholder)
+				if err := archive.addFile(placeholder, 0o644, modTime, PlaceholderContent(tc.Options.Placeholder, entry), "", ""); err != nil {
+					return fmt.Errorf("failed to add placeholder '%s' to the archive: %w", placeholder, err)
+				}
+			}
+		case "symlink":
+			linkTarget := GetEntryMetadataString(entry, "linkTarget")
+			if linkTarget == "" {
+				return fmt.Errorf("symlink '%s' has no target", name)
+			}
+			if err := archive.addSymlink(name, linkTarget, modTime); err != nil {
+				return fmt.Errorf("failed to add symlink '%s' to the archive: %w", name, err)
+			}
+		default:
+			content, err := tc.entryContent(entry)
+			if err != nil {
+				return err
+			}
+			owner, group := GetEntryMetadataString(entry, "owner"), GetEntryMetadataString(entry, "group")
+			if err := archive.addFile(name, mode, modTime, content, owner, group); err != nil {
+				return fmt.Errorf("failed to add file '%s' to the archive: %w", name, err)
+			}
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish the archive: %w", err)
+	}
+	return nil
+}
+
+// archiveMode returns the mode of an entry in the archive: the explicit permissions, the permissions of
+// the `from=` source (with PreserveModes), or the defaults (0755 for directories, 0644 for files).
+func (tc *TreeComposer) archiveMode(entry it.IFileEntry) (os.FileMode, error) {
+	if HasExplicitPermissions(entry) {
+		mode, err := utl.ParsePermissions(entry.GetPermissions())
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse permissions for '%s': %w", entry.GetPath(), err)
+		}
+		return mode, nil
+	}
+	if tc.Options.PreserveModes {
+		source, err := EntrySourcePath(tc.Options.SourceFileSystem(), tc.FileTree, entry)
+		if err != nil {
+			return 0, err
+		}
+		if source != "" {
+			if info, statErr := tc.Options.SourceFileSystem().Stat(source); statErr == nil {
+				return info.Mode().Perm(), nil
+			}
+		}
+	}
+	if entry.GetType() == "directory" {
+		return 0o755, nil
+	}
+	return 0o644, nil
+}
+
+// archiveSourceDir adds the contents of a `from=` source directory under the path of its entry.
+func (tc *TreeComposer) archiveSourceDir(archive archiveWriter, source, name string, written map[string]bool) error {
+	fsys := tc.Options.SourceFileSystem()
+	return fsys.Walk(source, func(srcPath string, info os.FileInfo, err error) error {
+		if err != nil || srcPath == source {
+			return err
+		}
+		rel, err := filepath.Rel(source, srcPath)
+		if err != nil {
+			return err
+		}
+		archiveName := path.Join(name, filepath.ToSlash(rel))
+		if written[archiveName] {
+			return nil
+		}
+		written[archiveName] = true
+		mode := os.FileMode(0o644)
+		if info.IsDir() {
+			mode = 0o755
+		}
+		if tc.Options.PreserveModes {
+			mode = info.Mode().Perm()
+		}
+		switch {
+		case info.IsDir():
+			return archive.addDir(archiveName, mode, info.ModTime())
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := fsys.Readlink(srcPath)
+			if err != nil {
+				return err
+			}
+			return archive.addSymlink(archiveName, target, info.ModTime())
+		default:
+			data, err := fsys.ReadFile(srcPath)
+			if err != nil {
+				return err
+			}
+			return archive.addFile(archiveName, mode, info.ModTime(), data, "", "")
+		}
+	})
+}
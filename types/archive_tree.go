@@ -0,0 +1,242 @@
+package types
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// ArchiveTreeOptions holds the optional settings used while reading an archive into a tree.
+type ArchiveTreeOptions struct {
+	// Ignore are globs of the paths (relative to the archive root, after stripping) or names left out of the tree.
+	Ignore []string
+	// StripComponents removes the leading path components of the members (e.g. 1 for "bundle-1.2.0/...").
+	StripComponents int
+	// FS is the filesystem the archive is read from (the OS filesystem when nil).
+	FS it.IFileSystem
+}
+
+// FileSystem returns the filesystem the archive is read from.
+func (o *ArchiveTreeOptions) FileSystem() it.IFileSystem {
+	if o.FS == nil {
+		o.FS = utl.NewOsFileSystem()
+	}
+	return o.FS
+}
+
+// archiveMember is a member of an archive, as added to the tree.
+type archiveMember struct {
+	Name       string
+	Type       string
+	Mode       os.FileMode
+	Size       int64
+	ModTime    time.Time
+	LinkTarget string
+}
+
+// ReadArchiveTree builds a tree from the members of a tar, tar.gz or zip archive (by the extension), without
+// extracting it. The entries carry the size, mode, modification time and link target of the members.
+func ReadArchiveTree(archivePath string, options *ArchiveTreeOptions) (it.IFileTree, error) {
+	if options == nil {
+		options = &ArchiveTreeOptions{}
+	}
+	format, err := ArchiveFormat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := options.FileSystem().Open(archivePath)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to open archive: %s", err))
+		return nil, fmt.Errorf("failed to open archive '%s': %w", archivePath, err)
+	}
+	defer file.Close()
+	ft, err := ReadArchiveTreeFrom(file, format, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive '%s': %w", archivePath, err)
+	}
+	return ft, nil
+}
+
+// ReadArchiveTreeFrom builds a tree from an archive stream in the given format ("tar", "tar.gz" or "zip").
+func ReadArchiveTreeFrom(r io.Reader, format string, options *ArchiveTreeOptions) (it.IFileTree, error) {
+	if options == nil {
+		options = &ArchiveTreeOptions{}
+	}
+	var members []archiveMember
+	var err error
+	switch format {
+	case "tar":
+		members, err = readTarMembers(r)
+	case "tar.gz", "tgz":
+		gz, gzErr := gzip.NewReader(r)
+		if gzErr != nil {
+			return nil, gzErr
+		}
+		defer gz.Close()
+		members, err = readTarMembers(gz)
+	case "zip":
+		members, err = readZipMembers(r)
+	default:
+		return nil, fmt.Errorf("unsupported archive format '%s'", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ft := newEmptyFileTree("", "", false, nil, nil)
+	for _, member := range members {
+		name, ok := archiveMemberPath(member.Name, options.StripComponents)
+		if !ok || isScanIgnored(name, options.Ignore) || isUnderIgnored(name, options.Ignore) {
+			continue
+		}
+		entry := ft.GetEntryByPath(name)
+		if entry != nil && entry.GetType() != member.Type {
+			// O último membro com o mesmo caminho prevalece, como na extração
+			if err := ft.RemoveEntry(entry.GetID()); err != nil {
+				return nil, err
+			}
+			entry = nil
+		}
+		if entry == nil {
+			if entry, err = ft.AddEntryByPath(name, member.Type, ""); err != nil {
+				return nil, fmt.Errorf("invalid member '%s': %w", member.Name, err)
+			}
+		}
+		modifiedAt := member.ModTime
+		entry.SetModifiedAt(&modifiedAt)
+		entry.SetPermissions(utl.FormatPermissions(member.Mode))
+		if member.Type == "file" {
+			entry.SetSize(member.Size)
+		}
+		if member.LinkTarget != "" {
+			SetEntryMetadataValue(entry, "linkTarget", member.LinkTarget)
+		}
+	}
+	return ft, nil
+}
+
+// readTarMembers lists the members of a tar stream. Hard links become files, and devices and fifos are skipped.
+func readTarMembers(r io.Reader) ([]archiveMember, error) {
+	tr := tar.NewReader(r)
+	members := make([]archiveMember, 0)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return members, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		member := archiveMember{Name: header.Name, Mode: header.FileInfo().Mode(), Size: header.Size, ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			member.Type = "directory"
+		case tar.TypeSymlink:
+			member.Type, member.LinkTarget = "symlink", header.Linkname
+		case tar.TypeReg, tar.TypeLink, tar.TypeGNUSparse:
+			member.Type = "file"
+		default:
+			gl.Log("debug", fmt.Sprintf("Skipping archive member '%s' of type %q", header.Name, header.Typeflag))
+			continue
+		}
+		members = append(members, member)
+	}
+}
+
+// readZipMembers lists the members of a zip archive. Symlinks keep their target as the member content.
+func readZipMembers(r io.Reader) ([]archiveMember, error) {
+	var readerAt io.ReaderAt
+	var size int64
+	if file, ok := r.(interface {
+		io.ReaderAt
+		Stat() (os.FileInfo, error)
+	}); ok {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		readerAt, size = file, info.Size()
+	} else {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		readerAt, size = bytes.NewReader(data), int64(len(data))
+	}
+	zr, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]archiveMember, 0, len(zr.File))
+	for _, file := range zr.File {
+		mode := file.Mode()
+		member := archiveMember{Name: file.Name, Mode: mode, Size: int64(file.UncompressedSize64), ModTime: file.Modified}
+		switch {
+		case mode.IsDir() || strings.HasSuffix(file.Name, "/"):
+			member.Type = "directory"
+		case mode&os.ModeSymlink != 0:
+			target, err := readZipMember(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read symlink '%s': %w", file.Name, err)
+			}
+			member.Type, member.LinkTarget = "symlink", target
+		case mode.IsRegular():
+			member.Type = "file"
+		default:
+			gl.Log("debug", fmt.Sprintf("Skipping archive member '%s' with mode %s", file.Name, mode))
+			continue
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// readZipMember returns the content of a small zip member.
+func readZipMember(file *zip.File) (string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(data), err
+}
+
+// archiveMemberPath cleans the name of a member into an entry path, stripping the leading components.
+// Members escaping the archive root ("../x") or left empty by the stripping are rejected.
+func archiveMemberPath(name string, strip int) (string, bool) {
+	name = path.Clean(strings.TrimLeft(strings.ReplaceAll(name, "\\", "/"), "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	for i := 0; i < strip; i++ {
+		_, rest, found := strings.Cut(name, "/")
+		if !found {
+			return "", false
+		}
+		name = rest
+	}
+	return name, true
+}
+
+// isUnderIgnored reports if a path is below an ignored directory, as archives list their members flat.
+func isUnderIgnored(rel string, ignore []string) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if isScanIgnored(dir, ignore) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+var (
+	// heredocRegex matches an entry followed by a heredoc marker: `main.go <<EOF`, `main.go <<'EOF' # comment`.
+	heredocRegex = regexp.MustCompile(`^(.*?\S)\s*<<(['"]?)([A-Za-z_][A-Za-z0-9_]*)(['"]?)(\s+#.*)?$`)
+	// bodyHeaderRegex matches the `==> path <==` headers of the trailing bodies section.
+	bodyHeaderRegex = regexp.MustCompile(`^==>\s*(\S.*?)\s*<==$`)
+)
+
+// treeBody is the content of an entry declared in the trailing `---` section of a tree file.
+type treeBody struct {
+	Path    string   // Caminho da entrada, relativo à árvore ou ao diretório raiz único
+	Content string   // Conteúdo do arquivo
+	Line    treeLine // Linha do cabeçalho, usada nas mensagens de erro
+}
+
+// extractTreeBodies separates the inline file bodies of the tree lines. Heredoc bodies are stored in the
+// "body" metadata of the entry line, and the bodies of the trailing `---` section are returned apart:
+//
+//	app/
+//	├── main.go <<EOF
+//	│   package main
+//	│   EOF
+//	└── go.mod
+//	---
+//	==> app/go.mod <==
+//	module app
+func extractTreeBodies(lines []treeLine) ([]treeLine, []treeBody, error) {
+	remaining := make([]treeLine, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line.Text) == "---" {
+			bodies, err := parseBodySection(lines[i+1:])
+			return remaining, bodies, err
+		}
+		matches := heredocRegex.FindStringSubmatch(line.Text)
+		if matches == nil || matches[2] != matches[4] {
+			remaining = append(remaining, line)
+			continue
+		}
+		marker := matches[3]
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(strings.TrimLeft(lines[j].Text, " \t│")) == marker {
+				end = j
+				break
+			}
+		}
+		if end == -1 {
+			gl.Log("error", fmt.Sprintf("Unterminated heredoc at %s: %s", line.Position(), marker))
+			return nil, nil, fmt.Errorf("%s: heredoc '%s' is not terminated", line.Position(), marker)
+		}
+
+		meta := map[string]any{"body": dedentBody(lines[i+1 : end])}
+		for k, v := range line.Meta {
+			meta[k] = v
+		}
+		line.Text = matches[1] + matches[5]
+		line.Meta = meta
+		remaining = append(remaining, line)
+		i = end
+	}
+	return remaining, []treeBody{}, nil
+}
+
+// parseBodySection parses the lines after the `---` separator, each body starting at a `==> path <==` header.
+func parseBodySection(lines []treeLine) ([]treeBody, error) {
+	bodies := make([]treeBody, 0)
+	for _, line := range lines {
+		if matches := bodyHeaderRegex.FindStringSubmatch(strings.TrimSpace(line.Text)); matches != nil {
+			bodies = append(bodies, treeBody{Path: matches[1], Line: line})
+			continue
+		}
+		if len(bodies) == 0 {
+			if strings.TrimSpace(line.Text) != "" {
+				gl.Log("error", fmt.Sprintf("Body without a '==> path <==' header at %s", line.Position()))
+				return nil, fmt.Errorf("%s: body without a '==> path <==' header", line.Position())
+			}
+			continue
+		}
+		bodies[len(bodies)-1].Content += line.Text + "\n"
+	}
+	for i := range bodies {
+		bodies[i].Content = strings.TrimRight(bodies[i].Content, "\n") + "\n"
+	}
+	return bodies, nil
+}
+
+// dedentBody joins the heredoc lines, removing the indentation (and tree guides) common to all of them.
+func dedentBody(lines []treeLine) string {
+	prefix, first := "", true
+	for _, line := range lines {
+		if strings.Trim(line.Text, " \t│") == "" {
+			continue
+		}
+		lead := line.Text[:len(line.Text)-len(strings.TrimLeft(line.Text, " \t│"))]
+		if first {
+			prefix, first = lead, false
+			continue
+		}
+		for !strings.HasPrefix(lead, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	var body strings.Builder
+	for _, line := range lines {
+		if strings.Trim(line.Text, " \t│") != "" {
+			body.WriteString(strings.TrimPrefix(line.Text, prefix))
+		}
+		body.WriteString("\n")
+	}
+	return body.String()
+}
+
+// EntryBody returns the inline body of an entry (a heredoc, a `---` section or a Markdown fence). The bodies
+// are kept apart from the metadata, so they stay out of the JSON, query and diff output.
+func (ft *FileTree) EntryBody(entry it.IFileEntry) (string, bool) {
+	if entry == nil {
+		return "", false
+	}
+	body, ok := ft.bodies[entry.GetID()]
+	return body, ok
+}
+
+// SetEntryBody sets the inline body of an entry.
+func (ft *FileTree) SetEntryBody(entry it.IFileEntry, body string) {
+	if entry == nil {
+		return
+	}
+	if ft.bodies == nil {
+		ft.bodies = make(map[uuid.UUID]string)
+	}
+	ft.bodies[entry.GetID()] = body
+}
+
+// bindTreeBodies stores the bodies of the `---` section of a fragment in the "body" metadata of the fragment
+// lines they name, before the fragment is spliced, so every inclusion of it gets its own copy. The paths are
+// matched as written, relative to the fragment or to its single root directory; the bodies matching no line
+// (e.g. of entries instantiated by a macro) are returned to be resolved against the whole tree.
+func bindTreeBodies(lines []treeLine, bodies []treeBody) ([]treeLine, []treeBody, error) {
+	paths := make(map[string]int, len(lines))
+	stack := make([]string, 0)
+	roots, rootDir := 0, ""
+	for i, line := range lines {
+		prefix := utl.TreeLinePrefix(line.Text)
+		name, _, _ := strings.Cut(strings.TrimPrefix(line.Text, prefix), "#")
+		name = strings.TrimSpace(name)
+		depth := utl.TreeLineDepth(prefix)
+		if name == "" || strings.HasPrefix(name, "@") || depth > len(stack) {
+			continue
+		}
+		stack = append(stack[:depth], strings.Trim(name, "/"))
+		paths[strings.Join(stack, "/")] = i
+		if depth == 0 {
+			roots++
+			if strings.HasSuffix(name, "/") {
+				rootDir = stack[0]
+			}
+		}
+	}
+
+	remaining := make([]treeBody, 0)
+	for _, body := range bodies {
+		bodyPath := strings.Trim(body.Path, "/")
+		i, found := paths[bodyPath]
+		if !found && roots == 1 && rootDir != "" {
+			i, found = paths[rootDir+"/"+bodyPath]
+		}
+		if !found {
+			remaining = append(remaining, body)
+			continue
+		}
+		if strings.HasSuffix(strings.TrimSpace(strings.SplitN(lines[i].Text, "#", 2)[0]), "/") {
+			return nil, nil, fmt.Errorf("%s: '%s' is a directory and cannot have a body", body.Line.Position(), body.Path)
+		}
+		meta := map[string]any{"body": body.Content}
+		for k, v := range lines[i].Meta {
+			meta[k] = v
+		}
+		lines[i].Meta = meta
+	}
+	return lines, remaining, nil
+}
+
+// applyTreeBodies stores the bodies of the `---` section in their entries.
+func applyTreeBodies(ft *FileTree, bodies []treeBody) error {
+	for _, body := range bodies {
+		entry := ft.GetEntryByPath(resolveOverlayPath(ft, body.Path, false))
+		if entry == nil {
+			gl.Log("error", fmt.Sprintf("Entry of body not found at %s: %s", body.Line.Position(), body.Path))
+			return fmt.Errorf("%s: entry '%s' of the body not found in the tree", body.Line.Position(), body.Path)
+		}
+		if entry.GetType() == "directory" {
+			return fmt.Errorf("%s: '%s' is a directory and cannot have a body", body.Line.Position(), body.Path)
+		}
+		ft.SetEntryBody(entry, body.Content)
+	}
+	return nil
+}
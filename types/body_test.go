@@ -0,0 +1,37 @@
+package types
+
+import "testing"
+
+func TestFragmentBodies(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{name: "path with the fragment root", header: "==> cfg/app.yaml <=="},
+		{name: "path inside the fragment root", header: "==> app.yaml <=="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft, err := parseTestTreeFiles(t, map[string][]string{
+				"tree.txt": {"a/", "└── @include cfg.tree", "b/", "├── @include cfg.tree", "└── main.go <<EOF", "    package b", "    EOF"},
+				"cfg.tree": {"cfg/", "└── app.yaml", "---", tt.header, "port: 80"},
+			})
+			if err != nil {
+				t.Fatalf("ParseTreeText() error = %v", err)
+			}
+			wantBodies := map[string]string{"a/cfg/app.yaml": "port: 80\n", "b/cfg/app.yaml": "port: 80\n", "b/main.go": "package b\n"}
+			for entryPath, want := range wantBodies {
+				entry := ft.GetEntryByPath(entryPath)
+				if entry == nil {
+					t.Fatalf("%s is missing", entryPath)
+				}
+				if got, ok := ft.EntryBody(entry); !ok || got != want {
+					t.Errorf("body of %s = %q, want %q", entryPath, got, want)
+				}
+				if _, exported := GetEntryMetadataValue(entry, "body"); exported {
+					t.Errorf("body of %s is in the metadata", entryPath)
+				}
+			}
+		})
+	}
+}
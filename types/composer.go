@@ -3,68 +3,302 @@ package types
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
 	utl "github.com/faelmori/cleandgo/utils"
 )
 
 type TreeComposer struct {
 	*FileTree
+	Options      *TreeComposerOptions
+	HookResults  []TreeHookResult  // Resultados dos hooks da última composição
+	generated    map[string][]byte // Conteúdo dos arquivos gerados pela composição, para o lock
+	kept         map[string]bool   // Arquivos gerados antes e apagados localmente, não recriados pelas atualizações
+	placeholders map[string]bool   // Marcadores criados por este composer, os únicos que ele remove
+	ignored      []string          // Globs dos caminhos protegidos pelo sync, deixados de fora da composição
+}
+
+// TreeComposerOptions holds the optional settings used while composing a tree.
+type TreeComposerOptions struct {
+	// ApplyOwnership applies the `owner`/`group` annotations (chown), which usually requires privileges.
+	ApplyOwnership bool
+	// NoSkeletons creates the new files empty, without the starter content of the skeletons.
+	NoSkeletons bool
+	// Skeletons are user skeletons overriding the built-in ones, keyed by extension or file name.
+	Skeletons map[string]string
+	// PreserveModes keeps the permissions of the `from=` sources in the copied entries.
+	PreserveModes bool
+	// Placeholder is the file dropped in the empty directories (e.g. ".gitkeep", or "README.md" generated from
+	// the directory comment) and removed once they get other contents. Empty disables the placeholders.
+	Placeholder string
+	// NoHooks skips the `@hook` lines and `run:` annotations after composing.
+	NoHooks bool
+	// DryRun only logs the entries that would be created and the hooks that would run.
+	DryRun bool
+	// TemplatePacks render the content of the entries they have a template for, before the skeletons.
+	TemplatePacks []*TemplatePack
+	// FS is the filesystem the tree is composed in (the OS filesystem when nil).
+	FS it.IFileSystem
+	// SourceFS is the filesystem the `from=` sources are read from (the OS filesystem when nil).
+	SourceFS it.IFileSystem
+	// NoJournal skips recording the created paths in the journal of the target, used to undo the composition.
+	NoJournal bool
+	// Lock records the generated contents in the lock of the target, used to upgrade the composition. Trees
+	// composed with template packs are always locked.
+	Lock bool
+}
+
+// FileSystem returns the filesystem the tree is composed in.
+func (o *TreeComposerOptions) FileSystem() it.IFileSystem {
+	if o.FS == nil {
+		o.FS = utl.NewOsFileSystem()
+	}
+	return o.FS
+}
+
+// SourceFileSystem returns the filesystem the `from=` sources are read from.
+func (o *TreeComposerOptions) SourceFileSystem() it.IFileSystem {
+	if o.SourceFS == nil {
+		o.SourceFS = utl.NewOsFileSystem()
+	}
+	return o.SourceFS
 }
 
 func NewTreeComposer(fileTree it.IFileTree) (it.ITreeComposer, error) {
+	return NewTreeComposerWithOptions(fileTree, nil)
+}
+
+func NewTreeComposerWithOptions(fileTree it.IFileTree, options *TreeComposerOptions) (it.ITreeComposer, error) {
 	if _, ok := fileTree.GetFileTreeType().(*FileTree); !ok {
 		return nil, fmt.Errorf("invalid file tree type")
 	}
+	if options == nil {
+		options = &TreeComposerOptions{}
+	}
 	return &TreeComposer{
 		FileTree: fileTree.GetFileTreeType().(*FileTree),
+		Options:  options,
 	}, nil
 }
-func (tc *TreeComposer) MakeTreeDirectories() error {
+
+// TargetPath returns the path of the entry inside the composer target directory, refusing the entries whose
+// path would leave it (absolute or with ".." components).
+func (tc *TreeComposer) TargetPath(entry it.IFileEntry) (string, error) {
+	if entryPathEscapes(entry.GetPath()) {
+		gl.Log("error", fmt.Sprintf("Entry '%s' is outside the composer target", entry.GetPath()))
+		return "", fmt.Errorf("entry '%s' is outside the composer target '%s'", entry.GetPath(), tc.FileTree.ComposerTargetPath)
+	}
+	return filepath.Join(tc.FileTree.ComposerTargetPath, filepath.FromSlash(entry.GetPath())), nil
+}
+
+// composedEntries returns the entries the composition works on: all of them, but the ones protected by the
+// ignore globs of a sync (and the entries below them).
+func (tc *TreeComposer) composedEntries() []it.IFileEntry {
 	entries := tc.FileTree.GetEntries()
+	if len(tc.ignored) == 0 {
+		return entries
+	}
+	composed := make([]it.IFileEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !isScanIgnored(entry.GetPath(), tc.ignored) && !isUnderIgnored(entry.GetPath(), tc.ignored) {
+			composed = append(composed, entry)
+		}
+	}
+	return composed
+}
+func (tc *TreeComposer) MakeTreeDirectories() error {
+	fsys := tc.Options.FileSystem()
+	entries := tc.composedEntries()
 	for _, entry := range entries {
 		if entry.GetType() == "directory" {
-			if utl.CheckFileExists(entry.GetPath()) {
-				continue
+			targetPath, err := tc.TargetPath(entry)
+			if err != nil {
+				return err
+			}
+			if !utl.CheckFileExistsIn(fsys, targetPath) {
+				if err := fsys.MkdirAll(targetPath, os.ModePerm); err != nil {
+					return fmt.Errorf("failed to create directory '%s': %w", targetPath, err)
+				}
+			}
+			source, err := EntrySourcePath(tc.Options.SourceFileSystem(), tc.FileTree, entry)
+			if err != nil {
+				return err
 			}
-			if err := os.MkdirAll(entry.GetPath(), os.ModePerm); err != nil {
-				return fmt.Errorf("failed to create directory '%s': %w", entry.GetPath(), err)
+			if source != "" {
+				// Diretórios existentes recebem só os arquivos que faltam da origem
+				if err := utl.CopyDir(tc.Options.SourceFileSystem(), source, fsys, targetPath, tc.Options.PreserveModes); err != nil {
+					return fmt.Errorf("failed to copy '%s' to '%s': %w", source, targetPath, err)
+				}
 			}
 		}
 	}
 	return nil
 }
 func (tc *TreeComposer) MakeTreeFiles() error {
-	entries := tc.FileTree.GetEntries()
+	fsys := tc.Options.FileSystem()
+	entries := tc.composedEntries()
 	for _, entry := range entries {
 		if entry.GetType() == "file" {
-			if utl.CheckFileExists(entry.GetPath()) {
+			targetPath, err := tc.TargetPath(entry)
+			if err != nil {
+				return err
+			}
+			if utl.CheckFileExistsIn(fsys, targetPath) || tc.kept[entry.GetPath()] {
 				continue
 			}
-			file, err := os.Create(entry.GetPath())
+			if err := fsys.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create parent directory for '%s': %w", targetPath, err)
+			}
+			content, err := tc.entryContent(entry)
 			if err != nil {
-				return fmt.Errorf("failed to create file '%s': %w", entry.GetPath(), err)
+				return err
+			}
+			if err := fsys.WriteFile(targetPath, content, 0644); err != nil {
+				return fmt.Errorf("failed to create file '%s': %w", targetPath, err)
+			}
+			if tc.generated != nil {
+				tc.generated[entry.GetPath()] = content
+			}
+			if tc.Options.PreserveModes {
+				if err := tc.preserveSourceMode(entry, targetPath); err != nil {
+					return err
+				}
 			}
-			defer file.Close()
 		}
 	}
 	return nil
 }
+
+// entryContent resolves the initial content of a new file: the inline body, the `from=` source, the
+// template packs or the skeletons, in this order.
+func (tc *TreeComposer) entryContent(entry it.IFileEntry) ([]byte, error) {
+	if body, ok := tc.FileTree.EntryBody(entry); ok {
+		return []byte(body), nil
+	}
+	source, err := EntrySourcePath(tc.Options.SourceFileSystem(), tc.FileTree, entry)
+	if err != nil {
+		return nil, err
+	}
+	if source != "" {
+		data, err := tc.Options.SourceFileSystem().ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source '%s' of '%s': %w", source, entry.GetPath(), err)
+		}
+		return data, nil
+	}
+	content, found, err := RenderTemplatePacks(tc.FileTree, entry, tc.Options.TemplatePacks, tc.FileTree.Values)
+	if err != nil || found {
+		return []byte(content), err
+	}
+	if tc.Options.NoSkeletons {
+		return []byte{}, nil
+	}
+	content, found, err = RenderSkeleton(entry, tc.Options.Skeletons, tc.FileTree.Values)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return []byte{}, nil
+	}
+	return []byte(content), nil
+}
+
+// preserveSourceMode copies the permissions of the `from=` source of an entry, when it has one.
+func (tc *TreeComposer) preserveSourceMode(entry it.IFileEntry, targetPath string) error {
+	source, err := EntrySourcePath(tc.Options.SourceFileSystem(), tc.FileTree, entry)
+	if err != nil || source == "" {
+		return err
+	}
+	info, err := tc.Options.SourceFileSystem().Stat(source)
+	if err != nil {
+		return fmt.Errorf("failed to stat source '%s': %w", source, err)
+	}
+	if err := tc.Options.FileSystem().Chmod(targetPath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set permissions for '%s': %w", targetPath, err)
+	}
+	return nil
+}
 func (tc *TreeComposer) MakeTreeSymlinks() error {
-	entries := tc.FileTree.GetEntries()
+	fsys := tc.Options.FileSystem()
+	entries := tc.composedEntries()
 	for _, entry := range entries {
 		if entry.GetType() == "symlink" {
-			if utl.CheckFileExists(entry.GetPath()) {
+			targetPath, err := tc.TargetPath(entry)
+			if err != nil {
+				return err
+			}
+			if utl.CheckFileExistsIn(fsys, targetPath) {
 				continue
 			}
-			if err := os.Symlink(entry.GetOriginName(), entry.GetPath()); err != nil {
-				return fmt.Errorf("failed to create symlink '%s' -> '%s': %w", entry.GetPath(), entry.GetOriginName(), err)
+			linkTarget := GetEntryMetadataString(entry, "linkTarget")
+			if linkTarget == "" {
+				return fmt.Errorf("symlink '%s' has no target", entry.GetPath())
+			}
+			if err := fsys.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create parent directory for '%s': %w", targetPath, err)
+			}
+			if err := fsys.Symlink(linkTarget, targetPath); err != nil {
+				return fmt.Errorf("failed to create symlink '%s' -> '%s': %w", targetPath, linkTarget, err)
 			}
 		}
 	}
 	return nil
 }
 func (tc *TreeComposer) MakeTree() error {
+	return tc.makeTree(!tc.Options.NoHooks, tc.locksTree())
+}
+
+// locksTree reports if the compositions are recorded in the lock: when asked, or when template packs are used.
+func (tc *TreeComposer) locksTree() bool {
+	return tc.Options.Lock || len(tc.Options.TemplatePacks) > 0
+}
+
+// makeTree composes the tree, running the hooks only when asked (sync and upgrade run them afterwards, for
+// the entries they created) and recording the generated contents in the lock when it succeeds.
+func (tc *TreeComposer) makeTree(runHooks, lock bool) (err error) {
+	if tc.Options.DryRun {
+		for _, entry := range tc.composedEntries() {
+			targetPath, err := tc.TargetPath(entry)
+			if err != nil {
+				return err
+			}
+			if !utl.CheckFileExistsIn(tc.Options.FileSystem(), targetPath) {
+				gl.Log("info", fmt.Sprintf("[dry-run] create %s %s", entry.GetType(), targetPath))
+			}
+		}
+		if !runHooks {
+			return nil
+		}
+		return tc.RunTreeHooks()
+	}
+	if lock {
+		// Registrado antes do journal, o lock é gravado depois dele, fora do gravador
+		tc.generated = make(map[string][]byte)
+		fsys := tc.Options.FileSystem()
+		defer func() {
+			// Uma composição que falhou não serve de base para as atualizações
+			if err == nil {
+				if lockErr := tc.updateTreeLock(fsys, tc.generated, nil); lockErr != nil {
+					err = fmt.Errorf("failed to write the lock: %w", lockErr)
+				}
+			}
+			tc.generated = nil
+		}()
+	}
+	if !tc.Options.NoJournal {
+		// Grava os caminhos criados, mesmo quando a composição falha no meio do caminho
+		fsys := tc.Options.FileSystem()
+		recorder := newJournalFileSystem(fsys, tc.FileTree.ComposerTargetPath)
+		tc.Options.FS = recorder
+		defer func() {
+			tc.Options.FS = fsys
+			if journalErr := tc.writeTreeJournal(recorder); journalErr != nil && err == nil {
+				err = fmt.Errorf("failed to write the journal: %w", journalErr)
+			}
+		}()
+	}
 	if err := tc.MakeTreeDirectories(); err != nil {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
@@ -74,6 +308,22 @@ func (tc *TreeComposer) MakeTree() error {
 	if err := tc.MakeTreeSymlinks(); err != nil {
 		return fmt.Errorf("failed to create symlinks: %w", err)
 	}
+	if err := tc.EnsureTreePlaceholders(); err != nil {
+		return fmt.Errorf("failed to update placeholders: %w", err)
+	}
+	if err := tc.EnsureTreePermissions(); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if tc.Options.ApplyOwnership {
+		if err := tc.EnsureTreeOwnership(); err != nil {
+			return fmt.Errorf("failed to set ownership: %w", err)
+		}
+	}
+	if runHooks {
+		if err := tc.RunTreeHooks(); err != nil {
+			return fmt.Errorf("failed to run hooks: %w", err)
+		}
+	}
 	return nil
 }
 func (tc *TreeComposer) SetFilePermissions(path, permissions string) error {
@@ -81,36 +331,62 @@ func (tc *TreeComposer) SetFilePermissions(path, permissions string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse permissions for '%s': %w", path, err)
 	}
-	if err := os.Chmod(path, perms); err != nil {
+	if err := tc.Options.FileSystem().Chmod(path, perms); err != nil {
 		return fmt.Errorf("failed to set permissions for '%s': %w", path, err)
 	}
 	return nil
 }
 func (tc *TreeComposer) SetFileChecksum(path, checksum string) error {
-	if err := utl.SetFileChecksum(path, checksum); err != nil {
+	if err := utl.SetFileChecksum(tc.Options.FileSystem(), path, checksum); err != nil {
 		return fmt.Errorf("failed to set checksum for '%s': %w", path, err)
 	}
 	return nil
 }
 func (tc *TreeComposer) EnsureTreePermissions() error {
-	entries := tc.FileTree.GetEntries()
+	entries := tc.composedEntries()
 	for _, entry := range entries {
-		if entry.GetPermissions() == "" {
-			continue
+		if !HasExplicitPermissions(entry) || entry.GetType() == "symlink" {
+			continue // Mantém as permissões padrão do sistema (links não têm permissões próprias)
 		}
-		if err := tc.SetFilePermissions(entry.GetPath(), entry.GetPermissions()); err != nil {
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return err
+		}
+		if err := tc.SetFilePermissions(targetPath, entry.GetPermissions()); err != nil {
 			return fmt.Errorf("failed to set permissions for '%s': %w", entry.GetPath(), err)
 		}
 	}
 	return nil
 }
+func (tc *TreeComposer) EnsureTreeOwnership() error {
+	entries := tc.composedEntries()
+	for _, entry := range entries {
+		owner, group := GetEntryMetadataString(entry, "owner"), GetEntryMetadataString(entry, "group")
+		if owner == "" && group == "" {
+			continue
+		}
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return err
+		}
+		if err := utl.ChownByName(tc.Options.FileSystem(), targetPath, owner, group); err != nil {
+			return fmt.Errorf("failed to set ownership for '%s': %w", entry.GetPath(), err)
+		}
+	}
+	return nil
+}
 func (tc *TreeComposer) EnsureTreeChecksums() error {
-	entries := tc.FileTree.GetEntries()
+	entries := tc.composedEntries()
 	for _, entry := range entries {
-		if entry.GetChecksum() == "" {
+		checksum := EntryChecksum(entry)
+		if checksum == "" || entry.GetType() != "file" {
 			continue
 		}
-		if ok, err := utl.CheckFileChecksum(entry.GetPath(), entry.GetChecksum()); err != nil {
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return err
+		}
+		if ok, err := utl.CheckFileChecksum(tc.Options.FileSystem(), targetPath, checksum); err != nil {
 			return fmt.Errorf("failed to check checksum for '%s': %w", entry.GetPath(), err)
 		} else if !ok {
 			return fmt.Errorf("checksum mismatch for '%s'", entry.GetPath())
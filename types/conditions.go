@@ -0,0 +1,87 @@
+package types
+
+import (
+	"regexp"
+	"strings"
+
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// conditionRegex matches the `if: feature` and `unless: feature` annotations inside a line comment.
+// A comma separated list requires all the features, and a leading "!" negates one of them.
+var conditionRegex = regexp.MustCompile(`\b(if|unless):\s*(!?[\w.-]+(?:\s*,\s*!?[\w.-]+)*)`)
+
+// filterConditionalLines drops the lines, and the subtrees below them, whose `# if:` or `# unless:`
+// annotations don't match the enabled features. The annotations are removed from the kept lines.
+func filterConditionalLines(lines []treeLine, features map[string]bool) []treeLine {
+	filtered := make([]treeLine, 0, len(lines))
+	skipDepth := -1
+	for _, tl := range lines {
+		line := tl.Text
+		depth := utl.LineDepth(line)
+		if skipDepth >= 0 {
+			if depth > skipDepth || strings.TrimSpace(strings.TrimPrefix(line, utl.TreeLinePrefix(line))) == "" {
+				continue // Ainda dentro da subárvore excluída
+			}
+			skipDepth = -1
+		}
+
+		head, comment, hasComment := strings.Cut(line, "#")
+		if !hasComment || !conditionRegex.MatchString(comment) {
+			filtered = append(filtered, tl)
+			continue
+		}
+
+		include := true
+		for _, match := range conditionRegex.FindAllStringSubmatch(comment, -1) {
+			if matchFeatures(match[2], features) != (match[1] == "if") {
+				include = false
+			}
+		}
+		if !include {
+			skipDepth = depth
+			continue
+		}
+
+		comment = strings.TrimSpace(conditionRegex.ReplaceAllString(comment, ""))
+		tl.Text = strings.TrimRight(head, " \t")
+		if comment != "" {
+			tl.Text += " # " + comment
+		}
+		filtered = append(filtered, tl)
+	}
+	return filtered
+}
+
+// matchFeatures reports if all the features of a comma separated list hold.
+func matchFeatures(list string, features map[string]bool) bool {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		negate := strings.HasPrefix(name, "!")
+		if features[strings.TrimPrefix(name, "!")] == negate {
+			return false
+		}
+	}
+	return true
+}
+
+// lineConditions returns the `if:`/`unless:` annotations of the comment of a tree line, as written.
+func lineConditions(text string) string {
+	_, comment, found := strings.Cut(text, "#")
+	if !found {
+		return ""
+	}
+	return strings.Join(conditionRegex.FindAllString(comment, -1), " ")
+}
+
+// addLineConditions appends `if:`/`unless:` annotations to the comment of a tree line, so they also hold
+// for the line and its subtree.
+func addLineConditions(text, conditions string) string {
+	if conditions == "" || strings.TrimSpace(text) == "" {
+		return text
+	}
+	if strings.Contains(text, "#") {
+		return text + " " + conditions
+	}
+	return text + "  # " + conditions
+}
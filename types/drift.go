@@ -0,0 +1,207 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// TreeDriftOptions holds the optional settings used while comparing a tree with a directory.
+type TreeDriftOptions struct {
+	// Ignore are globs of the paths (relative to the directory) or names left out of the comparison.
+	Ignore []string
+	// Placeholders are additional placeholder names, not reported as extras when they are placeholders.
+	Placeholders []string
+	// StripComponents removes the leading path components of the members, when comparing with an archive.
+	StripComponents int
+	// FS is the filesystem of the directory or archive (the OS filesystem when nil).
+	FS it.IFileSystem
+}
+
+// FileSystem returns the filesystem of the directory or archive.
+func (o *TreeDriftOptions) FileSystem() it.IFileSystem {
+	if o.FS == nil {
+		o.FS = utl.NewOsFileSystem()
+	}
+	return o.FS
+}
+
+// TreeDrift is a difference between a tree and a directory.
+type TreeDrift struct {
+	Kind     string `json:"kind" yaml:"kind" xml:"kind" toml:"kind"`                                                         // "missing", "extra", "rename", "type", "target", "mode" ou "checksum"
+	Path     string `json:"path" yaml:"path" xml:"path" toml:"path"`                                                         // Caminho relativo ao diretório (o da árvore nas renomeações)
+	Type     string `json:"type" yaml:"type" xml:"type" toml:"type"`                                                         // Tipo da entrada
+	Expected string `json:"expected,omitempty" yaml:"expected,omitempty" xml:"expected,omitempty" toml:"expected,omitempty"` // Valor esperado pela árvore
+	Actual   string `json:"actual,omitempty" yaml:"actual,omitempty" xml:"actual,omitempty" toml:"actual,omitempty"`         // Valor encontrado no diretório (o caminho nas renomeações)
+}
+
+// String describes the drift in a line.
+func (d TreeDrift) String() string {
+	switch d.Kind {
+	case "missing", "extra":
+		return fmt.Sprintf("%-8s %s (%s)", d.Kind, d.Path, d.Type)
+	case "rename":
+		return fmt.Sprintf("%-8s %s -> %s (likely renamed)", d.Kind, d.Path, d.Actual)
+	}
+	return fmt.Sprintf("%-8s %s: expected %s, found %s", d.Kind, d.Path, d.Expected, d.Actual)
+}
+
+// TreeDriftReport is the outcome of comparing a tree with a directory.
+type TreeDriftReport struct {
+	Dir     string      `json:"dir" yaml:"dir" xml:"dir" toml:"dir"`                 // Diretório comparado
+	Checked int         `json:"checked" yaml:"checked" xml:"checked" toml:"checked"` // Número de entradas da árvore verificadas
+	Drifts  []TreeDrift `json:"drifts" yaml:"drifts" xml:"drifts" toml:"drifts"`     // Diferenças, pelo caminho
+}
+
+// HasDrift reports if the directory differs from the tree.
+func (r *TreeDriftReport) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+// DetectTreeDrift compares a tree with a directory, reporting the entries missing on disk, the extras on disk
+// (paths not in the tree, nor below a `from=` directory), the entries of the wrong type, the symlinks pointing
+// elsewhere and, when annotated, the wrong modes and checksums. Only the topmost path of a missing or extra
+// subtree is reported. A missing entry and an extra of the same type in the same directory with similar names
+// (see IsEqual) are reported as a likely rename.
+func DetectTreeDrift(ft it.IFileTree, dir string, options *TreeDriftOptions) (*TreeDriftReport, error) {
+	if options == nil {
+		options = &TreeDriftOptions{}
+	}
+	fsys := options.FileSystem()
+	tree, ok := ft.GetFileTreeType().(*FileTree)
+	if !ok {
+		return nil, fmt.Errorf("invalid file tree type")
+	}
+	scanned, err := ScanDirectoryTree(dir, &DirectoryScanOptions{Ignore: options.Ignore, Placeholders: options.Placeholders, FS: fsys})
+	if err != nil {
+		return nil, err
+	}
+	actual := scanned.GetFileTreeType().(*FileTree)
+	report := &TreeDriftReport{Dir: actual.ComposerTargetPath, Drifts: make([]TreeDrift, 0)}
+	checksum := func(entryPath, want string) (bool, error) {
+		return utl.CheckFileChecksum(fsys, filepath.Join(report.Dir, filepath.FromSlash(entryPath)), want)
+	}
+	return compareTreeDrift(tree, actual, report, options, checksum)
+}
+
+// DetectArchiveDrift compares a tree with the members of a tar, tar.gz or zip archive, as DetectTreeDrift does
+// with a directory. The members are not extracted, so the checksums are not checked, and the empty files with
+// a placeholder name are not reported as extras.
+func DetectArchiveDrift(ft it.IFileTree, archivePath string, options *TreeDriftOptions) (*TreeDriftReport, error) {
+	if options == nil {
+		options = &TreeDriftOptions{}
+	}
+	tree, ok := ft.GetFileTreeType().(*FileTree)
+	if !ok {
+		return nil, fmt.Errorf("invalid file tree type")
+	}
+	read, err := ReadArchiveTree(archivePath, &ArchiveTreeOptions{Ignore: options.Ignore, StripComponents: options.StripComponents, FS: options.FileSystem()})
+	if err != nil {
+		return nil, err
+	}
+	actual := read.GetFileTreeType().(*FileTree)
+	placeholders := make(map[string]bool)
+	for _, name := range append(append([]string{}, DefaultPlaceholderNames...), options.Placeholders...) {
+		placeholders[name] = true
+	}
+	for _, entry := range append([]it.IFileEntry{}, actual.GetEntries()...) {
+		if entry.GetType() == "file" && entry.GetSize() == 0 && placeholders[entry.GetName()] {
+			if err := actual.RemoveEntry(entry.GetID()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	report := &TreeDriftReport{Dir: archivePath, Drifts: make([]TreeDrift, 0)}
+	return compareTreeDrift(tree, actual, report, options, nil)
+}
+
+// compareTreeDrift fills the report with the drifts between the tree and the tree read from the directory or
+// archive. The checksums are checked by the given function, when there is one.
+func compareTreeDrift(tree, actual *FileTree, report *TreeDriftReport, options *TreeDriftOptions, checksum func(entryPath, want string) (bool, error)) (*TreeDriftReport, error) {
+
+	missing := make([]TreeDrift, 0)
+	missingPaths := make(map[string]bool)
+	for _, entry := range tree.GetEntries() {
+		entryPath := entry.GetPath()
+		if isScanIgnored(entryPath, options.Ignore) || isUnderIgnored(entryPath, options.Ignore) {
+			continue
+		}
+		report.Checked++
+		existing := actual.GetEntryByPath(entryPath)
+		if existing == nil {
+			if !isSyncCovered(entryPath, missingPaths) {
+				missing = append(missing, TreeDrift{Kind: "missing", Path: entryPath, Type: entry.GetType()})
+			}
+			missingPaths[entryPath] = true
+			continue
+		}
+		if existing.GetType() != entry.GetType() {
+			report.Drifts = append(report.Drifts, TreeDrift{Kind: "type", Path: entryPath, Type: entry.GetType(), Expected: entry.GetType(), Actual: existing.GetType()})
+			missingPaths[entryPath] = true // Os filhos esperados não são comparados com outro tipo
+			continue
+		}
+		switch entry.GetType() {
+		case "symlink":
+			if want, have := GetEntryMetadataString(entry, "linkTarget"), GetEntryMetadataString(existing, "linkTarget"); want != have {
+				report.Drifts = append(report.Drifts, TreeDrift{Kind: "target", Path: entryPath, Type: "symlink", Expected: want, Actual: have})
+			}
+			continue
+		case "file":
+			if want := EntryChecksum(entry); want != "" && checksum != nil {
+				matches, checkErr := checksum(entryPath, want)
+				if checkErr != nil {
+					return nil, fmt.Errorf("failed to check the checksum of '%s': %w", entryPath, checkErr)
+				}
+				if !matches {
+					report.Drifts = append(report.Drifts, TreeDrift{Kind: "checksum", Path: entryPath, Type: "file", Expected: want, Actual: "different content"})
+				}
+			}
+		}
+		if HasExplicitPermissions(entry) {
+			want, permErr := utl.ParsePermissions(entry.GetPermissions())
+			if permErr != nil {
+				return nil, fmt.Errorf("failed to parse permissions for '%s': %w", entryPath, permErr)
+			}
+			if have, _ := utl.ParsePermissions(existing.GetPermissions()); have != want {
+				report.Drifts = append(report.Drifts, TreeDrift{Kind: "mode", Path: entryPath, Type: entry.GetType(), Expected: utl.FormatPermissions(want), Actual: existing.GetPermissions()})
+			}
+		}
+	}
+
+	extras := make([]TreeDrift, 0)
+	extraPaths := make(map[string]bool)
+	for _, entry := range actual.GetEntries() {
+		entryPath := entry.GetPath()
+		if tree.GetEntryByPath(entryPath) != nil || isSourceCopy(tree, entryPath) || isSyncCovered(entryPath, missingPaths) {
+			continue
+		}
+		if !isSyncCovered(entryPath, extraPaths) {
+			extras = append(extras, TreeDrift{Kind: "extra", Path: entryPath, Type: entry.GetType()})
+		}
+		extraPaths[entryPath] = true
+	}
+
+	// Um caminho ausente e um extra parecidos, no mesmo diretório, são provavelmente uma renomeação
+	renamed := make(map[string]bool)
+	for _, m := range missing {
+		for _, x := range extras {
+			if renamed[x.Path] || x.Type != m.Type || path.Dir(x.Path) != path.Dir(m.Path) || !IsEqual(path.Base(m.Path), path.Base(x.Path)) {
+				continue
+			}
+			report.Drifts = append(report.Drifts, TreeDrift{Kind: "rename", Path: m.Path, Type: m.Type, Expected: path.Base(m.Path), Actual: x.Path})
+			renamed[m.Path], renamed[x.Path] = true, true
+			break
+		}
+	}
+	for _, drift := range append(missing, extras...) {
+		if !renamed[drift.Path] {
+			report.Drifts = append(report.Drifts, drift)
+		}
+	}
+	sort.SliceStable(report.Drifts, func(i, j int) bool { return report.Drifts[i].Path < report.Drifts[j].Path })
+	return report, nil
+}
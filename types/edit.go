@@ -0,0 +1,451 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// TreeEditOptions holds the optional settings used while restructuring a directory through its tree view.
+type TreeEditOptions struct {
+	// Ignore are globs of the paths (relative to the directory) or names left out of the tree view, untouched.
+	Ignore []string
+	// QuarantineDir receives the deleted paths, under a directory named by the edit time, instead of removing
+	// them. Relative directories are resolved against the edited directory.
+	QuarantineDir string
+	// DryRun only reports the operations, changing nothing.
+	DryRun bool
+	// FS is the filesystem edited (the OS filesystem when nil).
+	FS it.IFileSystem
+}
+
+// FileSystem returns the filesystem edited.
+func (o *TreeEditOptions) FileSystem() it.IFileSystem {
+	if o.FS == nil {
+		o.FS = utl.NewOsFileSystem()
+	}
+	return o.FS
+}
+
+// TreeEditOperation is a change to a directory computed from its edited tree view.
+type TreeEditOperation struct {
+	Action  string `json:"action" yaml:"action" xml:"action" toml:"action"`                                             // "rename", "move", "relink", "create" ou "delete"
+	ID      string `json:"id,omitempty" yaml:"id,omitempty" xml:"id,omitempty" toml:"id,omitempty"`                     // ID da entrada editada (vazio nas criações)
+	Type    string `json:"type" yaml:"type" xml:"type" toml:"type"`                                                     // Tipo da entrada
+	Path    string `json:"path" yaml:"path" xml:"path" toml:"path"`                                                     // Caminho atual, relativo ao diretório
+	NewPath string `json:"newPath,omitempty" yaml:"newPath,omitempty" xml:"newPath,omitempty" toml:"newPath,omitempty"` // Novo caminho (renomeações e movimentações)
+	Target  string `json:"target,omitempty" yaml:"target,omitempty" xml:"target,omitempty" toml:"target,omitempty"`     // Destino dos symlinks criados ou religados
+}
+
+// String describes the operation in a line, as shown in the previews.
+func (op TreeEditOperation) String() string {
+	switch op.Action {
+	case "rename", "move":
+		return fmt.Sprintf("%-7s %s -> %s", op.Action, op.Path, op.NewPath)
+	case "relink":
+		return fmt.Sprintf("%-7s %s -> %s", op.Action, op.Path, op.Target)
+	case "create":
+		if op.Type == "symlink" {
+			return fmt.Sprintf("%-7s %s -> %s", op.Action, op.Path, op.Target)
+		}
+		if op.Type == "directory" {
+			return fmt.Sprintf("%-7s %s/", op.Action, op.Path)
+		}
+	}
+	return fmt.Sprintf("%-7s %s", op.Action, op.Path)
+}
+
+// TreeEdit restructures a directory by editing its tree view, like vidir: the directory is scanned into a tree
+// view where every entry carries a stable `@id=` annotation, and the edited view is compared with it by the IDs.
+// Entries whose line moved are moved (or renamed), lines without an ID are created and the IDs left out are
+// deleted. Directories move with their whole subtree.
+type TreeEdit struct {
+	Dir     string                   // Diretório editado (absoluto)
+	Tree    *FileTree                // Árvore do diretório, com os IDs nos metadados "id"
+	Options *TreeEditOptions         // Opções da edição
+	entries map[string]it.IFileEntry // Entradas da árvore pelo ID
+}
+
+// NewTreeEdit scans a directory and numbers its entries, in the order of the tree view.
+func NewTreeEdit(dir string, options *TreeEditOptions) (*TreeEdit, error) {
+	if options == nil {
+		options = &TreeEditOptions{}
+	}
+	ft, err := ScanDirectoryTree(dir, &DirectoryScanOptions{Ignore: options.Ignore, FS: options.FileSystem()})
+	if err != nil {
+		return nil, err
+	}
+	tree := ft.GetFileTreeType().(*FileTree)
+	te := &TreeEdit{
+		Dir:     tree.ComposerTargetPath,
+		Tree:    tree,
+		Options: options,
+		entries: make(map[string]it.IFileEntry),
+	}
+	te.numberEntries(tree.GetChildren(uuid.Nil))
+	return te, nil
+}
+
+// numberEntries sets the IDs of the entries depth first, so they grow down the tree view.
+func (te *TreeEdit) numberEntries(entries []it.IFileEntry) {
+	for _, entry := range entries {
+		id := fmt.Sprint(len(te.entries) + 1)
+		SetEntryMetadataValue(entry, "id", id)
+		te.entries[id] = entry
+		if entry.GetType() == "directory" {
+			te.numberEntries(te.Tree.GetChildren(entry.GetID()))
+		}
+	}
+}
+
+// Render draws the tree view to be edited, with the IDs of the entries and the names escaped.
+func (te *TreeEdit) Render() string {
+	return RenderTreeView(te.Tree, &TreeRenderOptions{IDs: true, Escape: true})
+}
+
+// Plan parses the edited tree view and returns the operations turning the directory into it. The moves and
+// renames come first, then the relinks, the creations and the deletions. Operations implied by a parent
+// directory (its children moving along) are left out.
+func (te *TreeEdit) Plan(edited string) ([]TreeEditOperation, error) {
+	ft, err := parseEditView(te.Dir, edited)
+	if err != nil {
+		return nil, err
+	}
+
+	final := make(map[string]string)      // ID -> caminho final
+	finalPaths := make(map[string]string) // caminho final -> ID (ou "" nas criações)
+	creates := make([]TreeEditOperation, 0)
+	relinks := make([]TreeEditOperation, 0)
+	for _, entry := range ft.GetEntries() {
+		entryPath := entry.GetPath()
+		if _, taken := finalPaths[entryPath]; taken {
+			return nil, fmt.Errorf("path '%s' appears more than once in the edited tree", entryPath)
+		}
+		id := GetEntryMetadataString(entry, "id")
+		finalPaths[entryPath] = id
+		if id == "" {
+			op := TreeEditOperation{Action: "create", Type: entry.GetType(), Path: entryPath, Target: GetEntryMetadataString(entry, "linkTarget")}
+			creates = append(creates, op)
+			continue
+		}
+		original, ok := te.entries[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown id %s at '%s'", id, entryPath)
+		}
+		if _, seen := final[id]; seen {
+			return nil, fmt.Errorf("id %s is used more than once (at '%s'), copies are not supported", id, entryPath)
+		}
+		final[id] = entryPath
+		if original.GetType() != "directory" && len(ft.GetChildren(entry.GetID())) > 0 {
+			return nil, fmt.Errorf("'%s' is a %s, it cannot have children", original.GetPath(), original.GetType())
+		}
+		if target := GetEntryMetadataString(entry, "linkTarget"); original.GetType() == "symlink" && target != "" && target != GetEntryMetadataString(original, "linkTarget") {
+			relinks = append(relinks, TreeEditOperation{Action: "relink", ID: id, Type: "symlink", Path: entryPath, Target: target})
+		}
+	}
+
+	// Uma linha recriada sem o ID, no mesmo caminho e com o mesmo tipo, mantém a entrada
+	kept := make([]TreeEditOperation, 0, len(creates))
+	for _, op := range creates {
+		original := te.Tree.GetEntryByPath(op.Path)
+		if original != nil && original.GetType() == op.Type && op.Type != "symlink" {
+			id := GetEntryMetadataString(original, "id")
+			parent := te.Tree.GetEntryByPath(path.Dir(op.Path))
+			_, survives := final[id]
+			if !survives && (parent == nil || final[GetEntryMetadataString(parent, "id")] == parent.GetPath()) {
+				final[id] = op.Path
+				finalPaths[op.Path] = id
+				continue
+			}
+		}
+		kept = append(kept, op)
+	}
+	creates = kept
+
+	ops := make([]TreeEditOperation, 0)
+	deletes := make([]TreeEditOperation, 0)
+	for _, original := range te.Tree.GetEntries() {
+		id := GetEntryMetadataString(original, "id")
+		originalPath := original.GetPath()
+		parentID := ""
+		if parent := te.Tree.GetEntryByPath(path.Dir(originalPath)); parent != nil {
+			parentID = GetEntryMetadataString(parent, "id")
+		}
+		newPath, survives := final[id]
+		if !survives {
+			if _, parentSurvives := final[parentID]; parentID != "" && !parentSurvives {
+				continue // Removida junto com o diretório pai
+			}
+			deletes = append(deletes, TreeEditOperation{Action: "delete", ID: id, Type: original.GetType(), Path: originalPath})
+			continue
+		}
+		if newPath == originalPath {
+			continue
+		}
+		if parentPath, parentSurvives := final[parentID]; parentID != "" && parentSurvives && path.Join(parentPath, original.GetName()) == newPath {
+			continue // Movida junto com o diretório pai
+		}
+		action := "move"
+		if path.Dir(newPath) == path.Dir(originalPath) {
+			action = "rename"
+		}
+		ops = append(ops, TreeEditOperation{Action: action, ID: id, Type: original.GetType(), Path: originalPath, NewPath: newPath})
+	}
+
+	// Os destinos não podem esconder caminhos fora da árvore (ignorados)
+	fsys := te.Options.FileSystem()
+	for _, op := range append(append([]TreeEditOperation{}, ops...), creates...) {
+		destination := op.NewPath
+		if op.Action == "create" {
+			destination = op.Path
+		}
+		if te.Tree.GetEntryByPath(destination) != nil {
+			continue
+		}
+		if utl.CheckFileExistsIn(fsys, filepath.Join(te.Dir, filepath.FromSlash(destination))) {
+			return nil, fmt.Errorf("'%s' already exists outside the edited tree", destination)
+		}
+	}
+
+	ops = append(ops, relinks...)
+	ops = append(ops, creates...)
+	return append(ops, deletes...), nil
+}
+
+// Apply runs the planned operations. The moved entries are first taken out to a staging directory inside the
+// state directory (deepest first), so swaps and moves into moved directories are safe, then the deleted paths
+// are removed (or quarantined), and the moved entries are placed at their new paths (shallowest first) before
+// the new entries are created.
+func (te *TreeEdit) Apply(ops []TreeEditOperation) error {
+	if te.Options.DryRun {
+		for _, op := range ops {
+			gl.Log("info", fmt.Sprintf("[dry-run] %s", op))
+		}
+		return nil
+	}
+	fsys := te.Options.FileSystem()
+	stamp := time.Now().Format("20060102-150405")
+	staging := filepath.Join(te.Dir, StateDirName, "edit-"+stamp)
+	quarantine := ""
+	if te.Options.QuarantineDir != "" {
+		quarantine = te.Options.QuarantineDir
+		if !filepath.IsAbs(quarantine) {
+			quarantine = filepath.Join(te.Dir, filepath.FromSlash(quarantine))
+		}
+		quarantine = filepath.Join(quarantine, stamp)
+	}
+	targetPath := func(entryPath string) string {
+		return filepath.Join(te.Dir, filepath.FromSlash(entryPath))
+	}
+
+	moves := make([]TreeEditOperation, 0)
+	for _, op := range ops {
+		if op.Action == "move" || op.Action == "rename" {
+			moves = append(moves, op)
+		}
+	}
+
+	// Retira as entradas movidas, das mais profundas para as mais rasas
+	sort.SliceStable(moves, func(i, j int) bool { return pathDepth(moves[i].Path) > pathDepth(moves[j].Path) })
+	staged := make(map[string]string) // caminho original -> ID
+	if len(moves) > 0 {
+		if err := fsys.MkdirAll(staging, os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+	}
+	for _, op := range moves {
+		if err := fsys.Rename(targetPath(op.Path), filepath.Join(staging, op.ID)); err != nil {
+			return fmt.Errorf("failed to move '%s' to the staging directory: %w", op.Path, err)
+		}
+		staged[op.Path] = op.ID
+	}
+	// Caminho atual de uma entrada, dentro da entrada retirada mais próxima
+	currentPath := func(entryPath string) string {
+		for dir := entryPath; dir != "."; dir = path.Dir(dir) {
+			if id, ok := staged[dir]; ok {
+				rel := strings.TrimPrefix(strings.TrimPrefix(entryPath, dir), "/")
+				return filepath.Join(staging, id, filepath.FromSlash(rel))
+			}
+		}
+		return targetPath(entryPath)
+	}
+
+	for _, op := range ops {
+		if op.Action != "delete" {
+			continue
+		}
+		current := currentPath(op.Path)
+		if quarantine == "" {
+			if err := fsys.RemoveAll(current); err != nil {
+				return fmt.Errorf("failed to delete '%s': %w", op.Path, err)
+			}
+			continue
+		}
+		quarantinePath := filepath.Join(quarantine, filepath.FromSlash(op.Path))
+		if err := fsys.MkdirAll(filepath.Dir(quarantinePath), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create quarantine for '%s': %w", op.Path, err)
+		}
+		if err := fsys.Rename(current, quarantinePath); err != nil {
+			return fmt.Errorf("failed to quarantine '%s': %w", op.Path, err)
+		}
+	}
+
+	// Coloca as entradas movidas, das mais rasas para as mais profundas
+	sort.SliceStable(moves, func(i, j int) bool { return pathDepth(moves[i].NewPath) < pathDepth(moves[j].NewPath) })
+	for _, op := range moves {
+		destination := targetPath(op.NewPath)
+		if err := fsys.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create parent of '%s': %w", op.NewPath, err)
+		}
+		if err := fsys.Rename(filepath.Join(staging, op.ID), destination); err != nil {
+			return fmt.Errorf("failed to move '%s' to '%s': %w", op.Path, op.NewPath, err)
+		}
+	}
+	if len(moves) > 0 {
+		if err := fsys.RemoveAll(staging); err != nil {
+			gl.Log("warn", fmt.Sprintf("Failed to remove staging directory: %s", err))
+		}
+		if contents, err := fsys.ReadDir(filepath.Dir(staging)); err == nil && len(contents) == 0 {
+			_ = fsys.Remove(filepath.Dir(staging))
+		}
+	}
+
+	for _, op := range ops {
+		entryPath := targetPath(op.Path)
+		switch {
+		case op.Action == "relink":
+			if err := fsys.Remove(entryPath); err != nil {
+				return fmt.Errorf("failed to remove symlink '%s': %w", op.Path, err)
+			}
+			if err := fsys.Symlink(filepath.FromSlash(op.Target), entryPath); err != nil {
+				return fmt.Errorf("failed to relink '%s': %w", op.Path, err)
+			}
+		case op.Action != "create" || utl.CheckFileExistsIn(fsys, entryPath):
+			continue
+		case op.Type == "directory":
+			if err := fsys.MkdirAll(entryPath, os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create directory '%s': %w", op.Path, err)
+			}
+		default:
+			if err := fsys.MkdirAll(filepath.Dir(entryPath), os.ModePerm); err != nil {
+				return fmt.Errorf("failed to create parent of '%s': %w", op.Path, err)
+			}
+			if op.Type == "symlink" {
+				if err := fsys.Symlink(filepath.FromSlash(op.Target), entryPath); err != nil {
+					return fmt.Errorf("failed to create symlink '%s': %w", op.Path, err)
+				}
+			} else if err := fsys.WriteFile(entryPath, []byte{}, 0644); err != nil {
+				return fmt.Errorf("failed to create file '%s': %w", op.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseEditView reads an edited tree view literally: names are taken as written (unescaped), and nothing of the
+// tree files is interpreted (templates, macros, includes, conditions, rules, hooks or inline bodies), as the
+// view describes a directory, not a template.
+func parseEditView(dir, text string) (*FileTree, error) {
+	ft := newEmptyFileTree(filepath.Join(dir, "tree-edit.txt"), dir, false, nil, nil)
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		prefix := utl.TreeLinePrefix(line)
+		name, linkTarget, comment, linked := splitEditViewLine(strings.TrimPrefix(line, prefix))
+		if name == "" {
+			continue // Linhas vazias ou só com comentários
+		}
+		entryType := "file"
+		switch {
+		case linked:
+			entryType = "symlink"
+		case strings.HasSuffix(name, "/"):
+			entryType = "directory"
+		}
+		if entryPathEscapes(name) {
+			return nil, fmt.Errorf("line %d: '%s' is outside the edited directory", i+1, name)
+		}
+		name = path.Clean(name)
+		if name == "." {
+			return nil, fmt.Errorf("line %d: invalid name '%s'", i+1, strings.TrimPrefix(line, prefix))
+		}
+		comments, annotations := utl.ParseAnnotations(comment)
+		if linked {
+			annotations["linkTarget"] = linkTarget
+		}
+		entry, err := NewFileEntry(uuid.New(), uuid.Nil, entryType, name, line, 0, 0, comments)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		ApplyEntryAnnotations(entry, annotations)
+		ft.AddEntry(entry)
+	}
+	if err := utl.SetTreeViewEntriesDeepness(ft); err != nil {
+		return nil, err
+	}
+	return ft, nil
+}
+
+// splitEditViewLine splits the text of an edit view line (without its drawing) in the unescaped name, the
+// symlink target and the comment. A backslash escapes the next character, and the spaces around the name are
+// dropped unless escaped.
+func splitEditViewLine(text string) (name, linkTarget, comment string, linked bool) {
+	var parts [2]strings.Builder
+	var kept [2]int // Tamanho até o último caractere escapado, mantido no corte dos espaços
+	part := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text):
+			i++
+			parts[part].WriteByte(text[i])
+			kept[part] = parts[part].Len()
+		case c == '#':
+			comment = strings.TrimSpace(text[i+1:])
+			i = len(text)
+		case part == 0 && strings.HasPrefix(text[i:], " -> "):
+			part, linked = 1, true
+			i += len(" -> ") - 1
+		default:
+			parts[part].WriteByte(c)
+		}
+	}
+	trim := func(p int) string {
+		s := parts[p].String()
+		return s[:kept[p]] + strings.TrimRight(s[kept[p]:], " \t")
+	}
+	return trim(0), strings.TrimLeft(trim(1), " "), comment, linked
+}
+
+// escapeEditViewText escapes the characters an edit view reads specially: backslashes, comment marks, the
+// arrow of the symlinks and the spaces or drawing glyphs around the text.
+func escapeEditViewText(text string) string {
+	var sb strings.Builder
+	runes := []rune(text)
+	for i, r := range runes {
+		switch {
+		case r == '\\' || r == '#' || r == '>':
+			sb.WriteByte('\\')
+		case i == 0 && (unicode.IsSpace(r) || strings.ContainsRune("│├└─", r)):
+			sb.WriteByte('\\')
+		case i == len(runes)-1 && unicode.IsSpace(r):
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// pathDepth returns the number of components of a slash separated path.
+func pathDepth(entryPath string) int {
+	return strings.Count(entryPath, "/") + 1
+}
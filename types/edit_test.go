@@ -0,0 +1,142 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+func TestTreeEditPlanApply(t *testing.T) {
+	const dir = "/work"
+	tests := []struct {
+		name  string
+		files map[string]string // Arquivos iniciais (os diretórios terminam com "/")
+		view  []string          // Visão editada, com %[n]s trocado pelo ID do n-ésimo caminho de ids
+		ids   []string
+		ops   []string
+		want  map[string]string // Conteúdo final dos arquivos ("/" nos diretórios)
+		gone  []string
+	}{
+		{
+			name:  "swap two files",
+			files: map[string]string{"a.txt": "A", "b.txt": "B"},
+			ids:   []string{"a.txt", "b.txt"},
+			view:  []string{"b.txt  # @id=%[1]s", "a.txt  # @id=%[2]s"},
+			ops:   []string{"rename  a.txt -> b.txt", "rename  b.txt -> a.txt"},
+			want:  map[string]string{"a.txt": "B", "b.txt": "A"},
+		},
+		{
+			name:  "swap a file and a directory",
+			files: map[string]string{"a/": "", "a/x.go": "X", "b": "B"},
+			ids:   []string{"a", "a/x.go", "b"},
+			view:  []string{"a  # @id=%[3]s", "b/  # @id=%[1]s", "└── x.go  # @id=%[2]s"},
+			ops:   []string{"rename  a -> b", "rename  b -> a"},
+			want:  map[string]string{"a": "B", "b/x.go": "X"},
+		},
+		{
+			name:  "move into a moved directory",
+			files: map[string]string{"src/": "", "src/x.go": "X", "lib/": "", "lib/y.go": "Y"},
+			ids:   []string{"src", "src/x.go", "lib", "lib/y.go"},
+			view:  []string{"core/  # @id=%[1]s", "├── x.go  # @id=%[2]s", "└── y.go  # @id=%[4]s", "lib/  # @id=%[3]s"},
+			ops:   []string{"move    lib/y.go -> core/y.go", "rename  src -> core"},
+			want:  map[string]string{"core/x.go": "X", "core/y.go": "Y", "lib": "/"},
+			gone:  []string{"src", "lib/y.go"},
+		},
+		{
+			name:  "move a directory into a moved directory",
+			files: map[string]string{"a/": "", "a/x.go": "X", "b/": "", "b/y.go": "Y"},
+			ids:   []string{"a", "a/x.go", "b", "b/y.go"},
+			view:  []string{"c/  # @id=%[1]s", "├── x.go  # @id=%[2]s", "└── b/  # @id=%[3]s", "    └── y.go  # @id=%[4]s"},
+			ops:   []string{"rename  a -> c", "move    b -> c/b"},
+			want:  map[string]string{"c/x.go": "X", "c/b/y.go": "Y"},
+			gone:  []string{"a", "b"},
+		},
+		{
+			name:  "names read literally",
+			files: map[string]string{"notes # old.txt": "N", "{{x}}.txt": "T"},
+			ids:   []string{"notes # old.txt", "{{x}}.txt"},
+			view:  []string{"notes \\# new.txt  # @id=%[1]s", "{{x}}.txt  # @id=%[2]s"},
+			ops:   []string{"rename  notes # old.txt -> notes # new.txt"},
+			want:  map[string]string{"notes # new.txt": "N", "{{x}}.txt": "T"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := utl.NewMemFileSystem()
+			for name, content := range tt.files {
+				target := filepath.Join(dir, filepath.FromSlash(name))
+				if strings.HasSuffix(name, "/") {
+					if err := fsys.MkdirAll(target, os.ModePerm); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := fsys.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+				if err := fsys.WriteFile(target, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			te, err := NewTreeEdit(dir, &TreeEditOptions{FS: fsys})
+			if err != nil {
+				t.Fatalf("NewTreeEdit() error = %v", err)
+			}
+			// A visão sem edições não muda nada
+			if ops, err := te.Plan(te.Render()); err != nil || len(ops) != 0 {
+				t.Fatalf("Plan(Render()) = %v, %v; want no operations", ops, err)
+			}
+
+			ids := make([]any, 0, len(tt.ids))
+			for _, entryPath := range tt.ids {
+				entry := te.Tree.GetEntryByPath(entryPath)
+				if entry == nil {
+					t.Fatalf("%s was not scanned", entryPath)
+				}
+				ids = append(ids, GetEntryMetadataString(entry, "id"))
+			}
+			ops, err := te.Plan(fmt.Sprintf(strings.Join(tt.view, "\n"), ids...))
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+			got := make([]string, 0, len(ops))
+			for _, op := range ops {
+				got = append(got, op.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.ops, "\n") {
+				t.Errorf("Plan() = %q, want %q", got, tt.ops)
+			}
+
+			if err := te.Apply(ops); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			for name, want := range tt.want {
+				target := filepath.Join(dir, filepath.FromSlash(name))
+				if want == "/" {
+					if info, err := fsys.Stat(target); err != nil || !info.IsDir() {
+						t.Errorf("%s is not a directory", name)
+					}
+					continue
+				}
+				data, err := fsys.ReadFile(target)
+				if err != nil {
+					t.Errorf("ReadFile(%s) error = %v", name, err)
+				} else if string(data) != want {
+					t.Errorf("%s = %q, want %q", name, data, want)
+				}
+			}
+			for _, name := range tt.gone {
+				if utl.CheckFileExistsIn(fsys, filepath.Join(dir, filepath.FromSlash(name))) {
+					t.Errorf("%s still exists", name)
+				}
+			}
+			if utl.CheckFileExistsIn(fsys, filepath.Join(dir, StateDirName)) {
+				t.Errorf("the staging directory was left behind")
+			}
+		})
+	}
+}
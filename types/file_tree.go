@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -29,9 +30,45 @@ type FileTree struct {
 	DrawedMap          map[string]string    `json:"drawed" yaml:"drawed" xml:"drawed" toml:"drawed" gorm:"omitempty,drawed"`                                                   // Mapa de símbolos usados para desenhar a árvore
 	DirectoriesIcons   []string             `json:"directoriesIcons" yaml:"directoriesIcons" xml:"directoriesIcons" toml:"directoriesIcons" gorm:"omitempty,directoriesIcons"` // Ícones para diretórios
 	FilesIcons         []string             `json:"filesIcons" yaml:"filesIcons" xml:"filesIcons" toml:"filesIcons" gorm:"omitempty,filesIcons"`                               // Ícones para arquivos
+	Values             map[string]any       `json:"values" yaml:"values" xml:"-" toml:"values" gorm:"omitempty,type:jsonb"`                                                    // Variáveis de template da árvore
+	Features           []string             `json:"features" yaml:"features" xml:"features" toml:"features" gorm:"omitempty,features"`                                         // Features habilitadas para entradas condicionais
+	Overlays           []string             `json:"overlays" yaml:"overlays" xml:"overlays" toml:"overlays" gorm:"omitempty,overlays"`                                         // Arquivos de overlay aplicados sobre a árvore
+	Rules              []string             `json:"rules" yaml:"rules" xml:"rules" toml:"rules" gorm:"omitempty,rules"`                                                        // Regras de entradas geradas, além das `@rule` do arquivo
+	Hooks              []string             `json:"hooks" yaml:"hooks" xml:"hooks" toml:"hooks" gorm:"omitempty,hooks"`                                                        // Linhas `@hook` do arquivo, executadas após compor a árvore
+	RuleFiles          []string             `json:"ruleFiles" yaml:"ruleFiles" xml:"ruleFiles" toml:"ruleFiles" gorm:"omitempty,ruleFiles"`                                    // Arquivos de onde as regras foram lidas, gravados no lock
+	Profiles           []string             `json:"profiles" yaml:"profiles" xml:"profiles" toml:"profiles" gorm:"omitempty,profiles"`                                         // Perfis aplicados aos valores e features, gravados no lock
+	FS                 it.IFileSystem       `json:"-" yaml:"-" xml:"-" toml:"-" gorm:"-"`                                                                                      // Sistema de arquivos do backup do arquivo de árvore
+
+	bodies map[uuid.UUID]string // Conteúdo inline dos arquivos, fora dos metadados exportados
+}
+
+// FileTreeOptions holds the optional settings used while parsing a tree file.
+type FileTreeOptions struct {
+	// Values are the template variables available to names, comments and contents.
+	Values map[string]any
+	// Features enables the entries annotated with `# if: <feature>` (and disables the `# unless:` ones).
+	Features []string
+	// Overlays are applied, in order, on top of the parsed tree to produce the effective tree.
+	Overlays []string
+	// Rules are evaluated, with the `@rule` lines of the tree file, after parsing and overlays.
+	Rules []string
+	// RuleFiles are the files the Rules were loaded from, recorded in the lock so upgrades load them again.
+	RuleFiles []string
+	// Profiles are the profile files applied to the Values and Features, recorded in the lock so upgrades
+	// apply them again.
+	Profiles []string
+	// FS is the filesystem holding the tree file backups (the OS filesystem when nil).
+	FS it.IFileSystem
 }
 
 func NewFileTree(treeFileSource, composerTargetPath string, printTree bool, logger l.Logger, debug bool) (it.IFileTree, error) {
+	return NewFileTreeWithOptions(treeFileSource, composerTargetPath, printTree, logger, debug, nil)
+}
+
+func NewFileTreeWithOptions(treeFileSource, composerTargetPath string, printTree bool, logger l.Logger, debug bool, options *FileTreeOptions) (it.IFileTree, error) {
+	if options == nil {
+		options = &FileTreeOptions{}
+	}
 	// Logger resilient initialization
 	if logger == nil {
 		logger = l.GetLogger("CleandGO")
@@ -74,7 +111,28 @@ func NewFileTree(treeFileSource, composerTargetPath string, printTree bool, logg
 		}
 	}
 
-	fte := &FileTree{
+	fte := newEmptyFileTree(treeFileSource, composerTargetPath, printTree, logger, options)
+
+	if err := fte.ParseTree(); err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to parse tree source: %s", err.Error()))
+		return nil, fmt.Errorf("failed to parse tree source: %s", err.Error())
+	}
+
+	// Log the number of entries loaded
+	gl.Log("debug", fmt.Sprintf("FileTree parsed with %d entries", len(fte.Entries)))
+
+	return fte, nil
+}
+
+// newEmptyFileTree returns a FileTree without entries, with the drawing maps and the given options.
+func newEmptyFileTree(treeFileSource, composerTargetPath string, printTree bool, logger l.Logger, options *FileTreeOptions) *FileTree {
+	if options == nil {
+		options = &FileTreeOptions{}
+	}
+	if logger == nil {
+		logger = l.GetLogger("CleandGO")
+	}
+	return &FileTree{
 		Mutexes:            NewMutexesType(),
 		PrintTree:          printTree,
 		TreeFileSource:     treeFileSource,
@@ -104,17 +162,14 @@ func NewFileTree(treeFileSource, composerTargetPath string, printTree bool, logg
 		},
 		DirectoriesIcons: []string{"📂", "📁", "🗂"},
 		FilesIcons:       []string{"📜", "🔖", "🔥", "✔"},
+		Values:           options.Values,
+		Features:         options.Features,
+		Overlays:         options.Overlays,
+		Rules:            options.Rules,
+		RuleFiles:        options.RuleFiles,
+		Profiles:         options.Profiles,
+		FS:               options.FS,
 	}
-
-	if err := fte.ParseTree(); err != nil {
-		gl.Log("error", fmt.Sprintf("Failed to parse tree source: %s", err.Error()))
-		return nil, fmt.Errorf("failed to parse tree source: %s", err.Error())
-	}
-
-	// Log the number of entries loaded
-	gl.Log("debug", fmt.Sprintf("FileTree parsed with %d entries", len(fte.Entries)))
-
-	return fte, nil
 }
 
 func (ft *FileTree) GetEntries() []it.IFileEntry {
@@ -155,7 +210,9 @@ func (ft *FileTree) AddEntry(entry it.IFileEntry) {
 		ft.RootID = entry.GetID()
 	}
 
-	go ft.SetEntriesDepth() // Define a profundidade das entradas em segundo plano
+	// Define a profundidade da nova entrada na hora (as demais não mudam): regras, overlays e consultas
+	// leem a profundidade logo após o parse
+	entry.SetDepth(originEntryDepth(entry))
 }
 func (ft *FileTree) SetEntriesDepth() {
 	var maxDepth int
@@ -166,20 +223,25 @@ func (ft *FileTree) SetEntriesDepth() {
 	ft.MuLock()
 	defer ft.MuUnlock()
 
-	depthRegex := regexp.MustCompile(`^([\s│├└──]*)`)
 	for i, entry := range ft.Entries {
-		matches := depthRegex.FindStringSubmatch(entry.GetOriginName())
-		if len(matches) > 0 {
-			depth := strings.Count(matches[1], "│") + strings.Count(matches[1], "├") + strings.Count(matches[1], "└")
-			ft.Entries[i].SetDepth(depth)
-			if depth > maxDepth {
-				maxDepth = depth
-			}
-		} else {
-			ft.Entries[i].SetDepth(0)
+		depth := originEntryDepth(entry)
+		ft.Entries[i].SetDepth(depth)
+		if depth > maxDepth {
+			maxDepth = depth
 		}
 	}
 }
+
+// originDepthRegex matches the drawing that prefixes the origin line of an entry.
+var originDepthRegex = regexp.MustCompile(`^([\s│├└──]*)`)
+
+// originEntryDepth returns the depth of an entry from the drawing of its origin line.
+func originEntryDepth(entry it.IFileEntry) int {
+	if matches := originDepthRegex.FindStringSubmatch(entry.GetOriginName()); len(matches) > 0 {
+		return utl.TreeLineDepth(matches[1])
+	}
+	return 0
+}
 func (ft *FileTree) GetEntryByID(id uuid.UUID) it.IFileEntry {
 	for _, entry := range ft.Entries {
 		if entry.GetID() == id {
@@ -188,6 +250,204 @@ func (ft *FileTree) GetEntryByID(id uuid.UUID) it.IFileEntry {
 	}
 	return nil // Retorna nil se não encontrar
 }
+func (ft *FileTree) GetEntryByPath(entryPath string) it.IFileEntry {
+	entryPath = NormalizeEntryPath(entryPath)
+	if entryPath == "" {
+		return nil
+	}
+	for _, entry := range ft.Entries {
+		if entry.GetPath() == entryPath {
+			return entry
+		}
+	}
+	return nil // Retorna nil se não encontrar
+}
+func (ft *FileTree) AddEntryByPath(entryPath, entryType, comments string) (it.IFileEntry, error) {
+	if entryPathEscapes(entryPath) {
+		gl.Log("error", fmt.Sprintf("Entry path '%s' is outside the tree", entryPath))
+		return nil, fmt.Errorf("entry path '%s' is outside the tree", entryPath)
+	}
+	entryPath = NormalizeEntryPath(entryPath)
+	if entryPath == "" {
+		gl.Log("error", "Entry path cannot be empty")
+		return nil, fmt.Errorf("entry path cannot be empty")
+	}
+	if existing := ft.GetEntryByPath(entryPath); existing != nil {
+		return existing, nil // A entrada já existe na árvore
+	}
+
+	// Garante que os diretórios intermediários existam na árvore
+	var parent it.IFileEntry
+	if parentPath := path.Dir(entryPath); parentPath != "." {
+		parent = ft.GetEntryByPath(parentPath)
+		if parent == nil {
+			var parentErr error
+			if parent, parentErr = ft.AddEntryByPath(parentPath, "directory", ""); parentErr != nil {
+				return nil, parentErr
+			}
+		} else if parent.GetType() != "directory" {
+			gl.Log("error", fmt.Sprintf("Parent of '%s' is not a directory", entryPath))
+			return nil, fmt.Errorf("parent of '%s' is not a directory", entryPath)
+		}
+	}
+
+	depth := 0
+	if parent != nil {
+		depth = parent.GetDepth() + 1
+	}
+	name := path.Base(entryPath)
+	entry, err := NewFileEntry(uuid.New(), uuid.Nil, entryType, name, drawEntryOrigin(depth, name, entryType), depth, 0, comments)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to create entry '%s': %s", entryPath, err))
+		return nil, fmt.Errorf("failed to create entry '%s': %s", entryPath, err)
+	}
+	if parent != nil {
+		entry.SetParent(parent)
+	}
+	ft.insertEntries(parent, []it.IFileEntry{entry})
+	if ft.RootID == uuid.Nil && depth == 0 {
+		ft.RootID = entry.GetID()
+	}
+	return entry, nil
+}
+func (ft *FileTree) RemoveEntry(id uuid.UUID) error {
+	entry := ft.GetEntryByID(id)
+	if entry == nil {
+		gl.Log("error", fmt.Sprintf("Entry with ID '%s' not found in FileTree", id))
+		return fmt.Errorf("entry with ID '%s' not found", id)
+	}
+	// Remove a entrada e toda a sua subárvore
+	remaining := make([]it.IFileEntry, 0, len(ft.Entries))
+	for _, candidate := range ft.Entries {
+		if candidate.GetID() == id || IsEntryDescendant(candidate, id) {
+			if ft.EntriesMapOrigin[candidate.GetName()] == candidate.GetID() {
+				delete(ft.EntriesMapOrigin, candidate.GetName())
+			}
+			continue
+		}
+		remaining = append(remaining, candidate)
+	}
+	ft.Entries = remaining
+	if ft.RootID == id {
+		ft.RootID = uuid.Nil
+		for _, candidate := range ft.Entries {
+			if candidate.GetDepth() == 0 {
+				ft.RootID = candidate.GetID()
+				break
+			}
+		}
+	}
+	return nil
+}
+func (ft *FileTree) MoveEntry(id uuid.UUID, newPath string) error {
+	entry := ft.GetEntryByID(id)
+	if entry == nil {
+		gl.Log("error", fmt.Sprintf("Entry with ID '%s' not found in FileTree", id))
+		return fmt.Errorf("entry with ID '%s' not found", id)
+	}
+	if entryPathEscapes(newPath) {
+		gl.Log("error", fmt.Sprintf("Cannot move '%s': '%s' is outside the tree", entry.GetPath(), newPath))
+		return fmt.Errorf("cannot move '%s': '%s' is outside the tree", entry.GetPath(), newPath)
+	}
+	newPath = NormalizeEntryPath(newPath)
+	if newPath == "" {
+		return fmt.Errorf("new path for '%s' cannot be empty", entry.GetPath())
+	}
+	if existing := ft.GetEntryByPath(newPath); existing != nil {
+		if existing.GetID() == id {
+			return nil // Nada a fazer
+		}
+		gl.Log("error", fmt.Sprintf("Cannot move '%s': '%s' already exists", entry.GetPath(), newPath))
+		return fmt.Errorf("cannot move '%s': '%s' already exists", entry.GetPath(), newPath)
+	}
+
+	var parent it.IFileEntry
+	if parentPath := path.Dir(newPath); parentPath != "." {
+		if parent = ft.GetEntryByPath(parentPath); parent == nil {
+			var parentErr error
+			if parent, parentErr = ft.AddEntryByPath(parentPath, "directory", ""); parentErr != nil {
+				return parentErr
+			}
+		}
+		if parent.GetID() == id || IsEntryDescendant(parent, id) {
+			return fmt.Errorf("cannot move '%s' into itself", entry.GetPath())
+		}
+		if parent.GetType() != "directory" {
+			return fmt.Errorf("parent of '%s' is not a directory", newPath)
+		}
+	}
+
+	// Separa a subárvore da lista, atualiza a entrada e reinsere abaixo do novo pai
+	samePosition := -1
+	if oldParent := entry.GetParent(); (oldParent == nil && parent == nil) || (oldParent != nil && parent != nil && oldParent.GetID() == parent.GetID()) {
+		samePosition = 0 // Renomeação simples, mantém a posição original
+	}
+	subtree := make([]it.IFileEntry, 0)
+	remaining := make([]it.IFileEntry, 0, len(ft.Entries))
+	for _, candidate := range ft.Entries {
+		if candidate.GetID() == id || IsEntryDescendant(candidate, id) {
+			if candidate.GetID() == id && samePosition >= 0 {
+				samePosition = len(remaining)
+			}
+			subtree = append(subtree, candidate)
+		} else {
+			remaining = append(remaining, candidate)
+		}
+	}
+	ft.Entries = remaining
+
+	if ft.EntriesMapOrigin[entry.GetName()] == id {
+		delete(ft.EntriesMapOrigin, entry.GetName())
+	}
+	entry.SetName(path.Base(newPath))
+	ft.EntriesMapOrigin[entry.GetName()] = id
+	if parent != nil {
+		entry.SetParent(parent)
+	} else if fe, ok := entry.(*FileEntry); ok {
+		fe.Parent = nil
+		fe.ParentID = uuid.Nil
+	}
+
+	// Recalcula a profundidade e o desenho de origem da subárvore movida
+	for _, moved := range subtree {
+		depth := 0
+		if moved.GetParent() != nil {
+			depth = moved.GetParent().GetDepth() + 1
+		}
+		moved.SetDepth(depth)
+		moved.SetOriginName(drawEntryOrigin(depth, moved.GetName(), moved.GetType()))
+	}
+	if samePosition >= 0 {
+		ft.Entries = append(ft.Entries[:samePosition], append(subtree, ft.Entries[samePosition:]...)...)
+		return nil
+	}
+	ft.insertEntries(parent, subtree)
+	return nil
+}
+
+// insertEntries places the entries right after the last descendant of the parent (or at the end).
+func (ft *FileTree) insertEntries(parent it.IFileEntry, entries []it.IFileEntry) {
+	position := len(ft.Entries)
+	if parent != nil {
+		for i, candidate := range ft.Entries {
+			if candidate.GetID() == parent.GetID() || IsEntryDescendant(candidate, parent.GetID()) {
+				position = i + 1
+			}
+		}
+	}
+	updated := make([]it.IFileEntry, 0, len(ft.Entries)+len(entries))
+	updated = append(updated, ft.Entries[:position]...)
+	updated = append(updated, entries...)
+	updated = append(updated, ft.Entries[position:]...)
+	ft.Entries = updated
+
+	for _, entry := range entries {
+		ft.EntriesMapOrigin[entry.GetName()] = entry.GetID()
+		if entry.GetDepth() > ft.MaxDepth {
+			ft.MaxDepth = entry.GetDepth()
+		}
+	}
+}
 func (ft *FileTree) GetChildren(parentID uuid.UUID) []it.IFileEntry {
 	var children []it.IFileEntry
 	for _, entry := range ft.Entries {
@@ -323,47 +583,154 @@ func (ft *FileTree) ParseTree() error {
 	if treeFileSource != "" && !ft.PrintTree {
 		gl.Log("debug", fmt.Sprintf("Loading tree file from source: %s", treeFileSource))
 
-		file, err := os.Open(treeFileSource)
+		// Reset the current state, so the same tree can be parsed more than once
+		ft.Entries = make([]it.IFileEntry, 0)
+		ft.EntriesMapOrigin = make(map[string]uuid.UUID)
+		ft.RootID = uuid.Nil
+		ft.bodies = nil
+
+		// Read the tree file, splicing the `@include` fragments in place and separating the inline bodies
+		lines, bodies, err := expandTreeIncludes(treeFileSource, nil)
 		if err != nil {
-			gl.Log("error", fmt.Sprintf("Failed to open tree file: %s", err))
-			return nil
+			gl.Log("error", fmt.Sprintf("Failed to read tree file: %s", err))
+			return fmt.Errorf("failed to read tree file: %s", err)
 		}
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
 
-		// Scanner para ler o arquivo linha por linha
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" {
-				continue // Ignora linhas vazias
-			}
-			// Parse the line into a FileEntry
-			if entry, err := ParseFieldsFromTreeView(line, ft); err != nil {
-				gl.Log("error", fmt.Sprintf("Failed to parse line '%s': %s", line, err))
-				return fmt.Errorf("failed to parse line '%s': %s", line, err)
-			} else {
-				ft.AddEntry(entry) // Adiciona a entrada ao FileTree
-			}
+		if err := ft.parseTreeLines(lines, bodies); err != nil {
+			return err
+		}
+	} else {
+		gl.Log("debug", "No tree file provided, initializing empty FileTree")
+	}
+	return nil
+}
+
+// ParseTreeText parses a tree view held in memory (e.g. extracted from another document). The source names
+// the text in the messages, and relative `@include` paths are resolved against its directory.
+func (ft *FileTree) ParseTreeText(source, text string) error {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	ft.Entries = make([]it.IFileEntry, 0)
+	ft.EntriesMapOrigin = make(map[string]uuid.UUID)
+	ft.RootID = uuid.Nil
+	ft.bodies = nil
+
+	lines := make([]treeLine, 0)
+	for i, text := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		lines = append(lines, treeLine{Text: text, Source: source, Number: i + 1})
+	}
+	expanded, bodies, err := expandTreeLines(absSource, lines, []string{absSource})
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read tree text: %s", err))
+		return fmt.Errorf("failed to read tree text: %s", err)
+	}
+	return ft.parseTreeLines(expanded, bodies)
+}
+
+// parseTreeLines builds the entries from the expanded tree lines, then applies the bodies, overlays and rules.
+func (ft *FileTree) parseTreeLines(lines []treeLine, bodies []treeBody) error {
+	// Reject the conflict markers left by `cleandgo merge`, they must be resolved by hand
+	for _, line := range lines {
+		if text := strings.TrimSpace(line.Text); strings.HasPrefix(text, "<<<<<<<") || text == "=======" || strings.HasPrefix(text, ">>>>>>>") {
+			gl.Log("error", fmt.Sprintf("Unresolved merge conflict at %s", line.Position()))
+			return fmt.Errorf("unresolved merge conflict at %s", line.Position())
 		}
+	}
+
+	// Instantiate the `@define`/`@use` macros
+	lines, err := expandTreeMacros(lines, ft.Values)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to expand tree macros: %s", err))
+		return fmt.Errorf("failed to expand tree macros: %s", err)
+	}
+
+	// Remove the conditional entries (and their subtrees) not enabled by the features
+	features := make(map[string]bool)
+	for _, feature := range ft.Features {
+		features[strings.TrimSpace(feature)] = true
+	}
+	lines = filterConditionalLines(lines, features)
+
+	// Separate the `@rule` lines, they are evaluated after the tree is built
+	lines, inlineRules, err := extractTreeRules(lines)
+	if err != nil {
+		return fmt.Errorf("failed to parse tree rules: %s", err)
+	}
+
+	// Separate the `@hook` lines, they run after the tree is composed
+	if lines, ft.Hooks, err = extractTreeHooks(lines); err != nil {
+		return fmt.Errorf("failed to parse tree hooks: %s", err)
+	}
 
-		// Check for errors during scanning
-		if err := scanner.Err(); err != nil {
-			gl.Log("error", fmt.Sprintf("Error reading tree file: %s", err))
-			return nil
+	for _, line := range lines {
+		if line.Text == "" {
+			continue // Ignora linhas vazias
+		}
+		// Render the template variables before parsing, so names and comments can use them
+		rendered, renderErr := ft.renderTreeLine(line)
+		if renderErr != nil {
+			gl.Log("error", fmt.Sprintf("Failed to render line '%s': %s", line.Text, renderErr))
+			return renderErr
+		}
+		// Parse the line into a FileEntry
+		if entry, err := ParseFieldsFromTreeView(rendered, ft); err != nil {
+			gl.Log("error", fmt.Sprintf("Failed to parse line '%s': %s", line.Text, err))
+			return fmt.Errorf("failed to parse line '%s': %s", line.Text, err)
+		} else if entry != nil {
+			for key, value := range line.Meta {
+				if key == "body" {
+					ft.SetEntryBody(entry, fmt.Sprint(value))
+					continue
+				}
+				SetEntryMetadataValue(entry, key, value)
+			}
+			ft.AddEntry(entry) // Adiciona a entrada ao FileTree
 		}
+	}
+
+	gl.Log("debug", fmt.Sprintf("Loaded %d entries from tree file: %s", len(ft.Entries), ft.TreeFileSource))
+
+	// Set the deepness of the entries based on their structure
+	if err := utl.SetTreeViewEntriesDeepness(ft); err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to set tree view entries deepness: %s", err))
+		return fmt.Errorf("failed to set tree view entries deepness: %s", err)
+	}
 
-		gl.Log("debug", fmt.Sprintf("Loaded %d entries from tree file: %s", len(ft.Entries), treeFileSource))
+	// Attach the bodies of the trailing `---` sections to their entries
+	if err := applyTreeBodies(ft, bodies); err != nil {
+		return fmt.Errorf("failed to apply inline bodies: %s", err)
+	}
 
-		// Set the deepness of the entries based on their structure
-		if err := utl.SetTreeViewEntriesDeepness(ft); err != nil {
-			gl.Log("error", fmt.Sprintf("Failed to set tree view entries deepness: %s", err))
-			return fmt.Errorf("failed to set tree view entries deepness: %s", err)
+	// Apply the overlays on top of the base tree
+	for _, overlayPath := range ft.Overlays {
+		if err := ApplyTreeOverlay(ft, overlayPath); err != nil {
+			return fmt.Errorf("failed to apply overlay '%s': %s", overlayPath, err)
 		}
-	} else {
-		gl.Log("debug", "No tree file provided, initializing empty FileTree")
+	}
+
+	// Generate the companion entries declared by the rules
+	if _, err := ApplyTreeRules(ft, append(append([]string{}, ft.Rules...), inlineRules...)); err != nil {
+		return fmt.Errorf("failed to apply tree rules: %s", err)
 	}
 	return nil
 }
+
+func (ft *FileTree) renderTreeLine(line treeLine) (string, error) {
+	if !utl.HasTemplateActions(line.Text) {
+		return line.Text, nil
+	}
+	values := ft.Values
+	if values == nil {
+		values = make(map[string]any)
+	}
+	rendered, err := utl.RenderTemplate(line.Position(), line.Text, values)
+	if err != nil {
+		return "", fmt.Errorf("undefined or invalid variable at %s: %w", line.Position(), err)
+	}
+	return rendered, nil
+}
 func (ft *FileTree) SerializeToFile(format string) error {
 	// Do a backup before serializing the new content.
 	if err := ft.BackupTreeFile(); err != nil {
@@ -408,11 +775,20 @@ func (ft *FileTree) LoadFromFile(format string) error {
 
 	return nil
 }
+
+// FileSystem returns the filesystem holding the tree file backups.
+func (ft *FileTree) FileSystem() it.IFileSystem {
+	if ft.FS == nil {
+		ft.FS = utl.NewOsFileSystem()
+	}
+	return ft.FS
+}
 func (ft *FileTree) BackupTreeFile() error {
 	// Do a backup before serializing the new content.
-	if utl.CheckFileExists(ft.TreeFileSource) {
+	fsys := ft.FileSystem()
+	if utl.CheckFileExistsIn(fsys, ft.TreeFileSource) {
 		backupFile := fmt.Sprintf("%s.bak", ft.TreeFileSource)
-		if err := os.Rename(ft.TreeFileSource, backupFile); err != nil {
+		if err := fsys.Rename(ft.TreeFileSource, backupFile); err != nil {
 			gl.Log("error", fmt.Sprintf("Failed to create backup of tree file: %s", err))
 			return fmt.Errorf("failed to create backup of tree file: %s", err)
 		}
@@ -423,10 +799,11 @@ func (ft *FileTree) BackupTreeFile() error {
 	return nil
 }
 func (ft *FileTree) RestoreTreeFile() error {
-	if utl.CheckFileExists(fmt.Sprintf("%s.bak", ft.TreeFileSource)) {
+	fsys := ft.FileSystem()
+	if utl.CheckFileExistsIn(fsys, fmt.Sprintf("%s.bak", ft.TreeFileSource)) {
 		gl.Log("debug", fmt.Sprintf("Restoring backup file: %s.bak", ft.TreeFileSource))
 		backupFile := fmt.Sprintf("%s.bak", ft.TreeFileSource)
-		if restoreErr := os.Rename(backupFile, ft.TreeFileSource); restoreErr != nil {
+		if restoreErr := fsys.Rename(backupFile, ft.TreeFileSource); restoreErr != nil {
 			gl.Log("error", fmt.Sprintf("Failed to restore backup file: %s", restoreErr))
 			return fmt.Errorf("failed to restore backup file: %s", restoreErr)
 		}
@@ -450,6 +827,122 @@ func (ft *FileTree) GetFileTreeType() any {
 	return ft
 }
 
+// NormalizeEntryPath cleans an entry path to the slash separated form returned by GetPath. Paths leaving
+// the tree (absolute or with ".." components) normalize to "".
+func NormalizeEntryPath(entryPath string) string {
+	entryPath = strings.TrimSpace(strings.ReplaceAll(entryPath, "\\", "/"))
+	if entryPath == "" || entryPathEscapes(entryPath) {
+		return ""
+	}
+	entryPath = strings.Trim(path.Clean(entryPath), "/")
+	if entryPath == "." {
+		return ""
+	}
+	return entryPath
+}
+
+// driveLetterRegex matches the Windows drive prefix of a path (C:).
+var driveLetterRegex = regexp.MustCompile(`^[A-Za-z]:`)
+
+// entryPathEscapes reports if an entry path would leave the directory it is composed in: absolute paths,
+// drive prefixes and paths with a ".." component.
+func entryPathEscapes(entryPath string) bool {
+	entryPath = strings.TrimSpace(strings.ReplaceAll(entryPath, "\\", "/"))
+	if strings.HasPrefix(entryPath, "/") || driveLetterRegex.MatchString(entryPath) {
+		return true
+	}
+	for _, component := range strings.Split(entryPath, "/") {
+		if component == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// SingleRootEntry returns the root directory of the tree when it is the only top level entry.
+func SingleRootEntry(ft it.IFileTree) it.IFileEntry {
+	var root it.IFileEntry
+	for _, entry := range ft.GetEntries() {
+		if entry.GetParent() != nil {
+			continue
+		}
+		if root != nil {
+			return nil // Mais de uma entrada no topo da árvore
+		}
+		root = entry
+	}
+	if root == nil || root.GetType() != "directory" {
+		return nil
+	}
+	return root
+}
+
+// RootRelativePath returns the path of an entry relative to the single root directory of the tree
+// (or the full path when the tree has many top level entries). The root itself returns "".
+func RootRelativePath(ft it.IFileTree, entry it.IFileEntry) string {
+	entryPath := entry.GetPath()
+	if root := SingleRootEntry(ft); root != nil {
+		if root.GetID() == entry.GetID() {
+			return ""
+		}
+		return strings.TrimPrefix(entryPath, root.GetPath()+"/")
+	}
+	return entryPath
+}
+
+// IsEntryDescendant reports if the entry is below the ancestor in the tree.
+func IsEntryDescendant(entry it.IFileEntry, ancestorID uuid.UUID) bool {
+	for parent := entry.GetParent(); parent != nil; parent = parent.GetParent() {
+		if parent.GetID() == ancestorID {
+			return true
+		}
+	}
+	return false
+}
+
+// drawEntryOrigin builds the origin line of an entry that was not read from a tree file.
+func drawEntryOrigin(depth int, name, entryType string) string {
+	if entryType == "directory" {
+		name += "/"
+	}
+	return DrawTreePrefix(depth) + name
+}
+
+// treeLine is a raw line of a tree file, with the information about where it came from.
+type treeLine struct {
+	Text   string         // Texto original da linha
+	Source string         // Arquivo de árvore de onde a linha foi lida
+	Number int            // Número da linha no arquivo de origem
+	Meta   map[string]any // Metadados adicionados à entrada criada pela linha
+}
+
+// Position returns the "file:line" reference of the line, used in error messages.
+func (tl treeLine) Position() string {
+	return fmt.Sprintf("%s:%d", filepath.Base(tl.Source), tl.Number)
+}
+
+func readTreeLines(treeFileSource string) ([]treeLine, error) {
+	file, err := os.Open(treeFileSource)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := make([]treeLine, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, treeLine{
+			Text:   scanner.Text(),
+			Source: treeFileSource,
+			Number: len(lines) + 1,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
 func ParseFieldsFromTreeView(line string, ft it.IFileTree) (it.IFileEntry, error) {
 	// Verifica se é um diretório ou arquivo pelo último campo
 	entryType := "unknown"
@@ -459,6 +952,15 @@ func ParseFieldsFromTreeView(line string, ft it.IFileTree) (it.IFileEntry, error
 
 	lineEntry, comments := utl.ExtractComment(strings.TrimSpace(strings.ToValidUTF8(line, ""))) // Extrai o comentário, se houver
 
+	// Separa as anotações estruturadas (@key=value, key: value) do texto livre do comentário
+	comments, annotations := utl.ParseAnnotations(comments)
+
+	// Links são declarados como no `ls -l`: "nome -> alvo"
+	if linkName, linkTarget, isLink := strings.Cut(lineEntry, " -> "); isLink && strings.TrimSpace(linkTarget) != "" {
+		lineEntry = strings.TrimRight(linkName, " ")
+		annotations["linkTarget"] = strings.TrimSpace(linkTarget)
+	}
+
 	// Verifica se a linha contém os ícones de identificação de diretórios e arquivos,
 	// se sim, já determina o tipo de entrada e remove os ícones
 	if utl.ContainsIcon(lineEntry, ft.GetDirectoriesIcons()) {
@@ -476,6 +978,10 @@ func ParseFieldsFromTreeView(line string, ft it.IFileTree) (it.IFileEntry, error
 		entryType = "unknown" // Caso contrário, é um tipo desconhecido
 	}
 
+	if _, isLink := annotations["linkTarget"]; isLink {
+		entryType = "symlink"
+	}
+
 	lineEntry = utl.SanitizeLineIcons(lineEntry, ft.GetDirectoriesIcons(), ft.GetFilesIcons()) // Remove ícones de arquivo
 
 	// A ideia é buscar SEMPRE o último "bloco" válido de texto na linha, que venha depois de / ou \\,
@@ -496,8 +1002,9 @@ func ParseFieldsFromTreeView(line string, ft it.IFileTree) (it.IFileEntry, error
 	// Antes de criar a entrada, precisamos garantir que o nome esteja correto
 	name = strings.TrimSpace(name) // Remove espaços extras ao redor
 
-	// Nome original da linha, sem espaços extras
-	originName := strings.TrimSpace(strings.ToValidUTF8(line, ""))
+	// Nome original da linha, sem espaços extras à direita.
+	// A indentação à esquerda é mantida, pois faz parte da profundidade da entrada.
+	originName := strings.TrimRight(strings.ToValidUTF8(line, ""), " \t\r")
 
 	// Se o nome estiver vazio, não cria a entrada
 	if name == "" {
@@ -505,12 +1012,18 @@ func ParseFieldsFromTreeView(line string, ft it.IFileTree) (it.IFileEntry, error
 		return nil, nil
 	}
 
-	// Remove a estrutura da árvore de diretórios do nome, se existir
-	// Pega o último bloco após a última barra
-	name = strings.Split(name, "/")[len(strings.Split(name, "/"))-1]
-
-	// Pega o último bloco após a última barra invertida
-	name = strings.Split(name, "\\")[len(strings.Split(name, "\\"))-1]
+	// Nomes com barras (ex: "cmd/app/main.go") são mantidos como caminhos relativos,
+	// os diretórios intermediários serão criados pelo composer.
+	// Caminhos absolutos ou com ".." sairiam do destino, então são recusados.
+	if entryPathEscapes(name) {
+		gl.Log("error", fmt.Sprintf("Entry '%s' from line '%s' is outside the tree", name, line))
+		return nil, fmt.Errorf("entry '%s' is outside the tree", name)
+	}
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "" || name == "." {
+		gl.Log("warn", fmt.Sprintf("Invalid entry name from line '%s'", line))
+		return nil, nil
+	}
 
 	// Cria a entrada de arquivo
 	if entry, entryErr := NewFileEntry(
@@ -526,6 +1039,7 @@ func ParseFieldsFromTreeView(line string, ft it.IFileTree) (it.IFileEntry, error
 		gl.Log("error", fmt.Sprintf("Failed to create FileEntry from line '%s': %s", line, entryErr))
 		return nil, fmt.Errorf("failed to create FileEntry from line '%s': %s", line, entryErr)
 	} else {
+		ApplyEntryAnnotations(entry, annotations)
 		return entry, nil
 	}
 }
@@ -0,0 +1,227 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// hookRegex matches `@hook <glob> [!glob ...] => <command>`.
+var hookRegex = regexp.MustCompile(`^@hook\s+(.+?)\s*=>\s*(\S.*)$`)
+
+// TreeHook runs a command for each composed entry matching its globs:
+//
+//	@hook **/*.sh => chmod +x {{.Name}}
+//	@hook dir:services/* => go mod tidy
+//
+// The "dir:" and "file:" prefixes restrict the kind of the matched entries. The command is a template with
+// the tree values plus the Name, Stem, Ext, Path, Dir and Target (absolute path) of the entry.
+type TreeHook struct {
+	Kind     string   `json:"kind" yaml:"kind" xml:"kind" toml:"kind"`                 // "file", "dir" ou vazio (ambos)
+	Patterns []string `json:"patterns" yaml:"patterns" xml:"patterns" toml:"patterns"` // Globs das entradas, "!" exclui
+	Command  string   `json:"command" yaml:"command" xml:"command" toml:"command"`     // Template do comando
+	Text     string   `json:"text" yaml:"text" xml:"text" toml:"text"`                 // Texto original do hook
+}
+
+// TreeHookResult is the outcome of a command run (or planned, in dry run) after composing a tree.
+type TreeHookResult struct {
+	Entry    string `json:"entry" yaml:"entry" xml:"entry" toml:"entry"`             // Caminho da entrada
+	Command  string `json:"command" yaml:"command" xml:"command" toml:"command"`     // Comando renderizado
+	Dir      string `json:"dir" yaml:"dir" xml:"dir" toml:"dir"`                     // Diretório de trabalho
+	Output   string `json:"output" yaml:"output" xml:"output" toml:"output"`         // Saída combinada (stdout e stderr)
+	ExitCode int    `json:"exitCode" yaml:"exitCode" xml:"exitCode" toml:"exitCode"` // Código de saída
+	Skipped  bool   `json:"skipped" yaml:"skipped" xml:"skipped" toml:"skipped"`     // Não executado (dry run ou fora do disco)
+}
+
+// ParseTreeHook parses a hook, with or without the leading "@hook" keyword.
+func ParseTreeHook(text string) (*TreeHook, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "@hook") {
+		text = "@hook " + text
+	}
+	matches := hookRegex.FindStringSubmatch(text)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid hook '%s', expected '@hook <glob> => <command>'", text)
+	}
+	hook := &TreeHook{Command: strings.TrimSpace(matches[2]), Text: text}
+	for _, pattern := range splitDirectiveArgs(matches[1]) {
+		for _, kind := range []string{"file", "dir"} {
+			if strings.HasPrefix(pattern, kind+":") {
+				hook.Kind, pattern = kind, strings.TrimPrefix(pattern, kind+":")
+			}
+		}
+		hook.Patterns = append(hook.Patterns, pattern)
+	}
+	if len(hook.Patterns) == 0 {
+		return nil, fmt.Errorf("hook '%s' has no glob", text)
+	}
+	return hook, nil
+}
+
+// Matches reports if the entry is matched by the hook.
+func (h *TreeHook) Matches(ft it.IFileTree, entry it.IFileEntry) bool {
+	if (h.Kind == "file" && entry.GetType() != "file") || (h.Kind == "dir" && entry.GetType() != "directory") {
+		return false
+	}
+	candidates := []string{entry.GetPath(), RootRelativePath(ft, entry)}
+	matched := false
+	for _, pattern := range h.Patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		for _, candidate := range candidates {
+			if candidate != "" && utl.MatchGlob(strings.TrimPrefix(pattern, "!"), candidate) {
+				if exclude {
+					return false
+				}
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+// extractTreeHooks removes the `@hook` lines from the tree lines, returning them apart.
+func extractTreeHooks(lines []treeLine) ([]treeLine, []string, error) {
+	remaining := make([]treeLine, 0, len(lines))
+	hooks := make([]string, 0)
+	for _, line := range lines {
+		text := strings.TrimSpace(strings.TrimPrefix(line.Text, utl.TreeLinePrefix(line.Text)))
+		if !strings.HasPrefix(text, "@hook") {
+			remaining = append(remaining, line)
+			continue
+		}
+		if _, err := ParseTreeHook(text); err != nil {
+			gl.Log("error", fmt.Sprintf("Invalid hook at %s: %s", line.Position(), err))
+			return nil, nil, fmt.Errorf("%s: %w", line.Position(), err)
+		}
+		hooks = append(hooks, text)
+	}
+	return remaining, hooks, nil
+}
+
+// RunTreeHooks runs the commands declared for the composed entries, in the order of the tree: for each entry,
+// the matching `@hook` lines (in the order they were declared), then its own `run:` annotation. Commands run
+// in the directory of the entry (the parent directory, for files) and the first failure stops the run. Trees
+// composed in another filesystem than the OS one have their commands skipped.
+func (tc *TreeComposer) RunTreeHooks() error {
+	return tc.runTreeHooks(nil)
+}
+
+// runTreeHooks runs the hooks of the entries accepted by the filter (all of them when nil).
+func (tc *TreeComposer) runTreeHooks(filter func(entry it.IFileEntry) bool) error {
+	hooks := make([]*TreeHook, 0, len(tc.FileTree.Hooks))
+	for _, text := range tc.FileTree.Hooks {
+		hook, err := ParseTreeHook(text)
+		if err != nil {
+			return err
+		}
+		hooks = append(hooks, hook)
+	}
+
+	// Os comandos rodam no sistema operacional, então só fazem sentido em árvores compostas no disco
+	onDisk := utl.IsOsFileSystem(tc.Options.FileSystem())
+	tc.HookResults = make([]TreeHookResult, 0)
+	for _, entry := range tc.FileTree.GetEntries() {
+		if filter != nil && !filter(entry) {
+			continue
+		}
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return err
+		}
+		commands := make([]string, 0)
+		for _, hook := range hooks {
+			if !hook.Matches(tc.FileTree, entry) {
+				continue
+			}
+			command, err := utl.RenderTemplate(hook.Text, hook.Command, tc.hookData(entry, targetPath))
+			if err != nil {
+				return fmt.Errorf("failed to render hook '%s' for '%s': %w", hook.Text, entry.GetPath(), err)
+			}
+			commands = append(commands, command)
+		}
+		if command := GetEntryMetadataString(entry, "run"); command != "" {
+			commands = append(commands, command)
+		}
+
+		dir := targetPath
+		if entry.GetType() != "directory" {
+			dir = filepath.Dir(dir)
+		}
+		for _, command := range commands {
+			result := TreeHookResult{Entry: entry.GetPath(), Command: command, Dir: dir}
+			if tc.Options.DryRun {
+				result.Skipped = true
+				tc.HookResults = append(tc.HookResults, result)
+				gl.Log("info", fmt.Sprintf("[dry-run] %s $ %s", dir, command))
+				continue
+			}
+			if !onDisk {
+				result.Skipped = true
+				tc.HookResults = append(tc.HookResults, result)
+				gl.Log("warn", fmt.Sprintf("Hook '%s' for '%s' skipped: the tree was not composed on disk", command, entry.GetPath()))
+				continue
+			}
+			err := runHookCommand(&result)
+			tc.HookResults = append(tc.HookResults, result)
+			if err != nil {
+				gl.Log("error", fmt.Sprintf("Hook failed for '%s': %s", entry.GetPath(), err))
+				report := fmt.Sprintf("hook '%s' for '%s' failed in '%s' (exit code %d): %s", command, entry.GetPath(), dir, result.ExitCode, err)
+				if output := strings.TrimSpace(result.Output); output != "" {
+					report += "\n" + output
+				}
+				return fmt.Errorf("%s", report)
+			}
+			gl.Log("debug", fmt.Sprintf("Hook '%s' for '%s' succeeded", command, entry.GetPath()))
+		}
+	}
+	return nil
+}
+
+// hookData returns the data of the hook templates: the tree values plus the fields of the entry.
+func (tc *TreeComposer) hookData(entry it.IFileEntry, targetPath string) map[string]any {
+	data := make(map[string]any, len(tc.FileTree.Values)+6)
+	for k, v := range tc.FileTree.Values {
+		data[k] = v
+	}
+	ext := path.Ext(entry.GetName())
+	data["Name"] = entry.GetName()
+	data["Stem"] = strings.TrimSuffix(entry.GetName(), ext)
+	data["Ext"] = ext
+	data["Path"] = entry.GetPath()
+	data["Dir"] = path.Dir(entry.GetPath())
+	data["Target"] = targetPath
+	return data
+}
+
+// runHookCommand runs a command through the system shell, capturing its combined output in the result.
+func runHookCommand(result *TreeHookResult) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", result.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", result.Command)
+	}
+	var output bytes.Buffer
+	cmd.Dir = result.Dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = os.Environ()
+	err := cmd.Run()
+	result.Output = output.String()
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	} else if err != nil {
+		result.ExitCode = -1
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package types
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// includeRegex matches `@include path/to/fragment.tree` and `@include path/to/fragment.tree as <dir>`.
+var includeRegex = regexp.MustCompile(`^@include\s+(\S+)(?:\s+as\s+(\S+))?\s*(?:#.*)?$`)
+
+// expandTreeIncludes reads a tree file and splices its `@include` fragments at the depth of the directive.
+// Relative fragment paths are resolved against the including file, and the chain of includes
+// is tracked to detect cycles. The fragment origin is recorded in the metadata of the spliced lines.
+// The inline bodies of every file are extracted before the directives, so their contents are never parsed.
+// The `if:`/`unless:` annotations of the directive are carried onto the root lines of the fragment.
+func expandTreeIncludes(treeFileSource string, chain []string) ([]treeLine, []treeBody, error) {
+	absSource, err := filepath.Abs(treeFileSource)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, included := range chain {
+		if included == absSource {
+			cycle := strings.Join(append(chain, absSource), " -> ")
+			gl.Log("error", fmt.Sprintf("Include cycle detected: %s", cycle))
+			return nil, nil, fmt.Errorf("include cycle detected: %s", cycle)
+		}
+	}
+	lines, err := readTreeLines(absSource)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expandTreeLines(absSource, lines, append(chain, absSource))
+}
+
+// expandTreeLines extracts the inline bodies of the lines read from a tree file and splices its fragments.
+func expandTreeLines(absSource string, lines []treeLine, chain []string) ([]treeLine, []treeBody, error) {
+	lines, bodies, err := extractTreeBodies(lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expanded := make([]treeLine, 0, len(lines))
+	for _, line := range lines {
+		prefix := utl.TreeLinePrefix(line.Text)
+		matches := includeRegex.FindStringSubmatch(strings.TrimSpace(strings.TrimPrefix(line.Text, prefix)))
+		if matches == nil {
+			expanded = append(expanded, line)
+			continue
+		}
+
+		fragmentPath := matches[1]
+		if !filepath.IsAbs(fragmentPath) {
+			fragmentPath = filepath.Join(filepath.Dir(absSource), fragmentPath)
+		}
+		if !utl.CheckFileExists(fragmentPath) {
+			gl.Log("error", fmt.Sprintf("Included fragment not found at %s: %s", line.Position(), fragmentPath))
+			return nil, nil, fmt.Errorf("included fragment not found at %s: %s", line.Position(), fragmentPath)
+		}
+		fragment, fragmentBodies, fragmentErr := expandTreeIncludes(fragmentPath, chain)
+		if fragmentErr != nil {
+			return nil, nil, fragmentErr
+		}
+		// Os corpos do fragmento são ligados às suas próprias linhas, valendo em cada ponto de inclusão
+		if fragment, fragmentBodies, fragmentErr = bindTreeBodies(fragment, fragmentBodies); fragmentErr != nil {
+			return nil, nil, fragmentErr
+		}
+		bodies = append(bodies, fragmentBodies...)
+
+		// As condições do @include valem para as linhas raiz do fragmento (ou para o diretório do alias)
+		conditions := lineConditions(line.Text)
+		depth := utl.TreeLineDepth(prefix)
+		if alias := matches[2]; alias != "" {
+			// O fragmento é colocado dentro de um novo diretório com o nome informado
+			expanded = append(expanded, treeLine{
+				Text:   addLineConditions(DrawTreePrefix(depth)+strings.TrimSuffix(alias, "/")+"/", conditions),
+				Source: line.Source,
+				Number: line.Number,
+				Meta:   map[string]any{"include": fragmentPath},
+			})
+			depth++
+			conditions = ""
+		}
+		for _, fragmentLine := range fragment {
+			fragmentPrefix := utl.TreeLinePrefix(fragmentLine.Text)
+			if utl.TreeLineDepth(fragmentPrefix) == 0 && !defineRegex.MatchString(strings.TrimSpace(fragmentLine.Text)) {
+				fragmentLine.Text = addLineConditions(fragmentLine.Text, conditions)
+			}
+			if depth > 0 && strings.TrimSpace(fragmentLine.Text) != "" {
+				fragmentLine.Text = DrawTreePrefix(utl.TreeLineDepth(fragmentPrefix)+depth) + strings.TrimPrefix(fragmentLine.Text, fragmentPrefix)
+			}
+			if _, nested := fragmentLine.Meta["include"]; !nested {
+				meta := map[string]any{"include": fragmentPath, "includeLine": fragmentLine.Number}
+				for k, v := range fragmentLine.Meta {
+					meta[k] = v
+				}
+				fragmentLine.Meta = meta
+			}
+			expanded = append(expanded, fragmentLine)
+		}
+	}
+	return expanded, bodies, nil
+}
+
+// DrawTreePrefix returns a normalized drawing prefix for an entry at the given depth.
+func DrawTreePrefix(depth int) string {
+	if depth <= 0 {
+		return ""
+	}
+	return strings.Repeat("│   ", depth-1) + "├── "
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseTestTreeFiles writes the files of a tree (and its fragments) in a temporary directory and parses
+// its tree.txt with the given features.
+func parseTestTreeFiles(t *testing.T, files map[string][]string, features ...string) (*FileTree, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, lines := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := filepath.Join(dir, "tree.txt")
+	ft := newEmptyFileTree(source, dir, false, nil, &FileTreeOptions{Features: features})
+	return ft, ft.ParseTreeText(source, strings.Join(files["tree.txt"], "\n"))
+}
+
+func TestIncludeConditions(t *testing.T) {
+	fragment := []string{"Dockerfile  # container image", "compose/", "└── dev.yaml"}
+	tests := []struct {
+		name     string
+		tree     []string
+		features []string
+		paths    []string
+	}{
+		{
+			name:  "if: without the feature",
+			tree:  []string{"app/", "├── main.go", "└── @include docker.tree  # if: docker"},
+			paths: []string{"app", "app/main.go"},
+		},
+		{
+			name:     "if: with the feature",
+			tree:     []string{"app/", "├── main.go", "└── @include docker.tree  # if: docker"},
+			features: []string{"docker"},
+			paths:    []string{"app", "app/Dockerfile", "app/compose", "app/compose/dev.yaml", "app/main.go"},
+		},
+		{
+			name:     "unless: with the feature",
+			tree:     []string{"app/", "├── main.go", "└── @include docker.tree  # unless: docker"},
+			features: []string{"docker"},
+			paths:    []string{"app", "app/main.go"},
+		},
+		{
+			name:  "alias without the feature",
+			tree:  []string{"app/", "├── main.go", "└── @include docker.tree as deploy  # if: docker"},
+			paths: []string{"app", "app/main.go"},
+		},
+		{
+			name:     "alias with the feature",
+			tree:     []string{"app/", "└── @include docker.tree as deploy  # if: docker"},
+			features: []string{"docker"},
+			paths:    []string{"app", "app/deploy", "app/deploy/Dockerfile", "app/deploy/compose", "app/deploy/compose/dev.yaml"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft, err := parseTestTreeFiles(t, map[string][]string{"tree.txt": tt.tree, "docker.tree": fragment}, tt.features...)
+			if err != nil {
+				t.Fatalf("ParseTreeText() error = %v", err)
+			}
+			if got := treePaths(ft); !reflect.DeepEqual(got, tt.paths) {
+				t.Errorf("paths = %v, want %v", got, tt.paths)
+			}
+			if entry := ft.GetEntryByPath("app/Dockerfile"); entry != nil && EntryComments(entry) != "container image" {
+				t.Errorf("comment of app/Dockerfile = %q, want %q", EntryComments(entry), "container image")
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+const (
+	// StateDirName is the directory, under the composer target, holding the state of cleandgo.
+	StateDirName = ".cleandgo"
+	// JournalFileName is the journal of the compositions, inside the state directory.
+	JournalFileName = "journal.json"
+)
+
+// TreeJournal lists the paths created by each composition in a target directory, so they can be undone.
+type TreeJournal struct {
+	Version     int                 `json:"version" yaml:"version" xml:"version" toml:"version"`                 // Versão do formato do journal
+	Generations []JournalGeneration `json:"generations" yaml:"generations" xml:"generations" toml:"generations"` // Composições, da mais antiga à mais recente
+}
+
+// JournalGeneration is a composition recorded in the journal.
+type JournalGeneration struct {
+	ID        string         `json:"id" yaml:"id" xml:"id" toml:"id"`                             // Identificador da composição
+	CreatedAt time.Time      `json:"createdAt" yaml:"createdAt" xml:"createdAt" toml:"createdAt"` // Data da composição
+	TreeFile  string         `json:"treeFile" yaml:"treeFile" xml:"treeFile" toml:"treeFile"`     // Arquivo de árvore composto
+	Entries   []JournalEntry `json:"entries" yaml:"entries" xml:"entries" toml:"entries"`         // Caminhos criados, na ordem de criação
+}
+
+// JournalEntry is a path created by a composition, with what is needed to know if it changed since.
+type JournalEntry struct {
+	Path       string `json:"path" yaml:"path" xml:"path" toml:"path"`                                             // Caminho relativo ao destino ("." para o próprio destino)
+	Type       string `json:"type" yaml:"type" xml:"type" toml:"type"`                                             // "file", "directory" ou "symlink"
+	Checksum   string `json:"checksum,omitempty" yaml:"checksum,omitempty" xml:"checksum" toml:"checksum"`         // Checksum do conteúdo criado (arquivos)
+	LinkTarget string `json:"linkTarget,omitempty" yaml:"linkTarget,omitempty" xml:"linkTarget" toml:"linkTarget"` // Alvo do link criado (symlinks)
+}
+
+// JournalUndoOptions holds the optional settings used while undoing a composition.
+type JournalUndoOptions struct {
+	// Force removes the files and symlinks changed since the composition too.
+	Force bool
+	// DryRun only reports what would be removed, leaving the files and the journal as they are.
+	DryRun bool
+	// FS is the filesystem of the target directory (the OS filesystem when nil).
+	FS it.IFileSystem
+}
+
+// FileSystem returns the filesystem of the target directory.
+func (o *JournalUndoOptions) FileSystem() it.IFileSystem {
+	if o.FS == nil {
+		o.FS = utl.NewOsFileSystem()
+	}
+	return o.FS
+}
+
+// JournalUndoReport is the outcome of undoing a composition.
+type JournalUndoReport struct {
+	Generation string            `json:"generation" yaml:"generation" xml:"generation" toml:"generation"` // Composição desfeita
+	Removed    []string          `json:"removed" yaml:"removed" xml:"removed" toml:"removed"`             // Caminhos removidos
+	Missing    []string          `json:"missing" yaml:"missing" xml:"missing" toml:"missing"`             // Caminhos que já não existiam
+	Kept       map[string]string `json:"kept" yaml:"kept" xml:"-" toml:"kept"`                            // Caminhos mantidos e o motivo
+}
+
+// JournalPath returns the path of the journal of a target directory.
+func JournalPath(targetDir string) string {
+	return filepath.Join(targetDir, StateDirName, JournalFileName)
+}
+
+// LoadTreeJournal reads the journal of a target directory, returning an empty journal when there is none.
+func LoadTreeJournal(fsys it.IFileSystem, targetDir string) (*TreeJournal, error) {
+	data, err := fsys.ReadFile(JournalPath(targetDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return &TreeJournal{Version: 1, Generations: make([]JournalGeneration, 0)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	journal := &TreeJournal{}
+	if err := json.Unmarshal(data, journal); err != nil {
+		return nil, fmt.Errorf("invalid journal '%s': %w", JournalPath(targetDir), err)
+	}
+	return journal, nil
+}
+
+// SaveTreeJournal writes the journal of a target directory, removing it (and the state directory, when left
+// empty) once it has no generations.
+func SaveTreeJournal(fsys it.IFileSystem, targetDir string, journal *TreeJournal) error {
+	journalPath := JournalPath(targetDir)
+	if len(journal.Generations) == 0 {
+		if err := fsys.Remove(journalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+		if contents, err := fsys.ReadDir(filepath.Dir(journalPath)); err == nil && len(contents) == 0 {
+			_ = fsys.Remove(filepath.Dir(journalPath))
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(journalPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := fsys.WriteFile(journalPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// UndoTreeJournal removes the paths created by the last composition recorded in the journal of a target
+// directory, in the reverse order of creation. Files and symlinks changed since are kept (unless forced), as
+// are the directories holding anything else, so the files of the user are left alone.
+func UndoTreeJournal(targetDir string, options *JournalUndoOptions) (*JournalUndoReport, error) {
+	if options == nil {
+		options = &JournalUndoOptions{}
+	}
+	fsys := options.FileSystem()
+	absTarget, err := filepath.Abs(targetDir)
+	if err != nil {
+		return nil, err
+	}
+	journal, err := LoadTreeJournal(fsys, absTarget)
+	if err != nil {
+		return nil, err
+	}
+	if len(journal.Generations) == 0 {
+		gl.Log("error", fmt.Sprintf("No composition recorded in %s", absTarget))
+		return nil, fmt.Errorf("no composition recorded in '%s'", targetDir)
+	}
+	generation := journal.Generations[len(journal.Generations)-1]
+	report := &JournalUndoReport{Generation: generation.ID, Removed: make([]string, 0), Missing: make([]string, 0), Kept: make(map[string]string)}
+
+	removed := make(map[string]bool)
+	for i := len(generation.Entries) - 1; i >= 0; i-- {
+		entry := generation.Entries[i]
+		if entry.Path == "." {
+			continue // O próprio destino só é removido depois do journal
+		}
+		entryPath, err := journalTargetPath(absTarget, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		reason, exists := journalEntryChange(fsys, entryPath, entry, removed)
+		switch {
+		case !exists:
+			report.Missing = append(report.Missing, entry.Path)
+			continue
+		case reason != "" && (entry.Type == "directory" || !options.Force):
+			report.Kept[entry.Path] = reason
+			continue
+		}
+		if !options.DryRun {
+			if err := fsys.Remove(entryPath); err != nil {
+				return nil, fmt.Errorf("failed to remove '%s': %w", entry.Path, err)
+			}
+		}
+		removed[entryPath] = true
+		report.Removed = append(report.Removed, entry.Path)
+	}
+	if options.DryRun {
+		return report, nil
+	}
+
+	// Os arquivos desfeitos deixam o lock (antes do journal, que remove o diretório de estado vazio)
+	undone := append(append([]string{}, report.Removed...), report.Missing...)
+	if err := dropTreeLockPaths(fsys, absTarget, undone); err != nil {
+		return nil, err
+	}
+	journal.Generations = journal.Generations[:len(journal.Generations)-1]
+	if err := SaveTreeJournal(fsys, absTarget, journal); err != nil {
+		return nil, err
+	}
+	if len(generation.Entries) > 0 && generation.Entries[0].Path == "." {
+		if contents, err := fsys.ReadDir(absTarget); err == nil && len(contents) == 0 {
+			if err := fsys.Remove(absTarget); err != nil {
+				return nil, fmt.Errorf("failed to remove '%s': %w", targetDir, err)
+			}
+			report.Removed = append(report.Removed, ".")
+		}
+	}
+	return report, nil
+}
+
+// journalTargetPath resolves a journaled path inside the target, refusing the ones escaping it.
+func journalTargetPath(absTarget, entryPath string) (string, error) {
+	clean := path.Clean(entryPath)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("journaled path '%s' is outside the target", entryPath)
+	}
+	return filepath.Join(absTarget, filepath.FromSlash(clean)), nil
+}
+
+// journalEntryChange reports if a journaled path still exists and, if so, why it can't be removed safely
+// (empty when it is unchanged). Directories holding only removed paths count as empty, for the dry runs.
+func journalEntryChange(fsys it.IFileSystem, entryPath string, entry JournalEntry, removed map[string]bool) (string, bool) {
+	info, err := fsys.Lstat(entryPath)
+	if err != nil {
+		return "", false
+	}
+	switch entry.Type {
+	case "directory":
+		if !info.IsDir() {
+			return "replaced by a file", true
+		}
+		contents, err := fsys.ReadDir(entryPath)
+		if err != nil {
+			return err.Error(), true
+		}
+		for _, content := range contents {
+			if !removed[filepath.Join(entryPath, content.Name())] {
+				return "not empty", true
+			}
+		}
+		return "", true
+	case "symlink":
+		if info.Mode()&os.ModeSymlink == 0 {
+			return "replaced by another type", true
+		}
+		if target, err := fsys.Readlink(entryPath); err != nil || target != entry.LinkTarget {
+			return "link target changed", true
+		}
+		return "", true
+	default:
+		if !info.Mode().IsRegular() {
+			return "replaced by another type", true
+		}
+		if entry.Checksum == "" {
+			return "", true
+		}
+		if ok, err := utl.CheckFileChecksum(fsys, entryPath, entry.Checksum); err != nil || !ok {
+			return "modified since the composition", true
+		}
+		return "", true
+	}
+}
+
+// journalFileSystem records the paths created through it inside a target directory, for the journal.
+type journalFileSystem struct {
+	it.IFileSystem
+	target  string
+	created []string
+	known   map[string]bool
+}
+
+func newJournalFileSystem(fsys it.IFileSystem, target string) *journalFileSystem {
+	return &journalFileSystem{IFileSystem: fsys, target: filepath.Clean(target), known: make(map[string]bool)}
+}
+
+// Unwrap returns the recorded filesystem.
+func (j *journalFileSystem) Unwrap() it.IFileSystem {
+	return j.IFileSystem
+}
+
+// record adds a path created inside the target, if it did not exist before.
+func (j *journalFileSystem) record(name string, existed bool) {
+	name = filepath.Clean(name)
+	if existed || j.known[name] {
+		return
+	}
+	if rel, err := filepath.Rel(j.target, name); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return // Fora do destino
+	}
+	j.known[name] = true
+	j.created = append(j.created, name)
+}
+
+func (j *journalFileSystem) exists(name string) bool {
+	_, err := j.IFileSystem.Lstat(name)
+	return err == nil
+}
+
+func (j *journalFileSystem) Create(name string) (io.WriteCloser, error) {
+	existed := j.exists(name)
+	file, err := j.IFileSystem.Create(name)
+	if err == nil {
+		j.record(name, existed)
+	}
+	return file, err
+}
+
+func (j *journalFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	existed := j.exists(name)
+	err := j.IFileSystem.WriteFile(name, data, perm)
+	if err == nil {
+		j.record(name, existed)
+	}
+	return err
+}
+
+func (j *journalFileSystem) MkdirAll(dirPath string, perm os.FileMode) error {
+	missing := make([]string, 0)
+	for dir := filepath.Clean(dirPath); !j.exists(dir); dir = filepath.Dir(dir) {
+		missing = append([]string{dir}, missing...)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if err := j.IFileSystem.MkdirAll(dirPath, perm); err != nil {
+		return err
+	}
+	for _, dir := range missing {
+		j.record(dir, false)
+	}
+	return nil
+}
+
+func (j *journalFileSystem) Symlink(oldname, newname string) error {
+	existed := j.exists(newname)
+	err := j.IFileSystem.Symlink(oldname, newname)
+	if err == nil {
+		j.record(newname, existed)
+	}
+	return err
+}
+
+func (j *journalFileSystem) Remove(name string) error {
+	if err := j.IFileSystem.Remove(name); err != nil {
+		return err
+	}
+	name = filepath.Clean(name)
+	if j.known[name] {
+		delete(j.known, name)
+		for i, created := range j.created {
+			if created == name {
+				j.created = append(j.created[:i], j.created[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// generation builds the journal generation of the recorded paths, with their current state.
+func (j *journalFileSystem) generation(treeFile string) (JournalGeneration, error) {
+	generation := JournalGeneration{ID: uuid.New().String(), CreatedAt: time.Now(), TreeFile: treeFile, Entries: make([]JournalEntry, 0, len(j.created))}
+	for _, created := range j.created {
+		info, err := j.IFileSystem.Lstat(created)
+		if err != nil {
+			continue // Removido depois de criado
+		}
+		rel, err := filepath.Rel(j.target, created)
+		if err != nil {
+			return generation, err
+		}
+		entry := JournalEntry{Path: filepath.ToSlash(rel)}
+		switch {
+		case info.IsDir():
+			entry.Type = "directory"
+		case info.Mode()&os.ModeSymlink != 0:
+			entry.Type = "symlink"
+			if entry.LinkTarget, err = j.IFileSystem.Readlink(created); err != nil {
+				return generation, err
+			}
+		default:
+			entry.Type = "file"
+			if entry.Checksum, err = utl.FileChecksum(j.IFileSystem, created, ""); err != nil {
+				return generation, err
+			}
+		}
+		generation.Entries = append(generation.Entries, entry)
+	}
+	return generation, nil
+}
+
+// writeTreeJournal appends the paths recorded during a composition to the journal of the target directory.
+func (tc *TreeComposer) writeTreeJournal(recorder *journalFileSystem) error {
+	generation, err := recorder.generation(tc.FileTree.TreeFileSource)
+	if err != nil {
+		return fmt.Errorf("failed to record the composition: %w", err)
+	}
+	if len(generation.Entries) == 0 {
+		return nil
+	}
+	fsys := recorder.Unwrap()
+	journal, err := LoadTreeJournal(fsys, recorder.target)
+	if err != nil {
+		return err
+	}
+	journal.Generations = append(journal.Generations, generation)
+	return SaveTreeJournal(fsys, recorder.target, journal)
+}
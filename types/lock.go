@@ -0,0 +1,383 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+const (
+	// LockFileName is the lock of the generated files, inside the state directory.
+	LockFileName = "lock.json"
+	// LockBaseDirName is the directory, inside the state directory, with the content generated for each locked
+	// file: the base of the three-way merges made by the upgrades.
+	LockBaseDirName = "base"
+)
+
+// TreeLock records how the files of a target directory were generated: the tree file, the versions of the
+// template packs, the variables, features, overlays, rule files and profiles, and the checksum of the content
+// generated for each file, so a newer template can be applied later without losing the local edits.
+type TreeLock struct {
+	Version   int                `json:"version" yaml:"version" xml:"version" toml:"version"`                             // Versão do formato do lock
+	TreeFile  string             `json:"treeFile" yaml:"treeFile" xml:"treeFile" toml:"treeFile"`                         // Arquivo de árvore composto
+	Templates []TreeLockTemplate `json:"templates,omitempty" yaml:"templates,omitempty" xml:"templates" toml:"templates"` // Pacotes de templates usados
+	Values    map[string]any     `json:"values,omitempty" yaml:"values,omitempty" xml:"-" toml:"values"`                  // Variáveis de template da composição
+	Features  []string           `json:"features,omitempty" yaml:"features,omitempty" xml:"features" toml:"features"`     // Features habilitadas na composição
+	Overlays  []string           `json:"overlays,omitempty" yaml:"overlays,omitempty" xml:"overlays" toml:"overlays"`     // Overlays aplicados sobre a árvore
+	RuleFiles []string           `json:"ruleFiles,omitempty" yaml:"ruleFiles,omitempty" xml:"ruleFiles" toml:"ruleFiles"` // Arquivos de regras avaliados com a árvore
+	Profiles  []string           `json:"profiles,omitempty" yaml:"profiles,omitempty" xml:"profiles" toml:"profiles"`     // Perfis aplicados aos valores e features
+	UpdatedAt time.Time          `json:"updatedAt" yaml:"updatedAt" xml:"updatedAt" toml:"updatedAt"`                     // Data da última composição ou atualização
+	Files     map[string]string  `json:"files" yaml:"files" xml:"-" toml:"files"`                                         // Checksum do conteúdo gerado, pelo caminho relativo ao destino
+}
+
+// TreeLockTemplate is a template pack recorded in the lock.
+type TreeLockTemplate struct {
+	Source  string `json:"source" yaml:"source" xml:"source" toml:"source"`                         // Origem do pacote
+	Version string `json:"version,omitempty" yaml:"version,omitempty" xml:"version" toml:"version"` // Versão declarada no manifesto do pacote
+}
+
+// TreeUpgradeOptions holds the optional settings used while upgrading a target directory to a newer template.
+type TreeUpgradeOptions struct {
+	// DryRun only reports the actions, changing nothing.
+	DryRun bool
+}
+
+// TreeUpgradeAction is a change made (or planned, in dry run) to upgrade a target directory.
+type TreeUpgradeAction struct {
+	Action string `json:"action" yaml:"action" xml:"action" toml:"action"` // "create", "update", "merge", "conflict", "keep" ou "remove"
+	Path   string `json:"path" yaml:"path" xml:"path" toml:"path"`         // Caminho relativo ao destino
+	Detail string `json:"detail" yaml:"detail" xml:"detail" toml:"detail"` // Motivo da ação
+}
+
+// TreeUpgradeReport is the outcome of upgrading a target directory.
+type TreeUpgradeReport struct {
+	Actions   []TreeUpgradeAction `json:"actions" yaml:"actions" xml:"actions" toml:"actions"`         // Ações, pelo caminho
+	Conflicts []string            `json:"conflicts" yaml:"conflicts" xml:"conflicts" toml:"conflicts"` // Arquivos com conflitos a resolver
+}
+
+// HasConflicts reports if files were left with conflicts.
+func (r *TreeUpgradeReport) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// LockPath returns the path of the lock of a target directory.
+func LockPath(targetDir string) string {
+	return filepath.Join(targetDir, StateDirName, LockFileName)
+}
+
+// lockBasePath returns the path of the generated content of a locked file.
+func lockBasePath(targetDir, entryPath string) string {
+	return filepath.Join(targetDir, StateDirName, LockBaseDirName, filepath.FromSlash(entryPath))
+}
+
+// LoadTreeLock reads the lock of a target directory, returning an empty lock when there is none.
+func LoadTreeLock(fsys it.IFileSystem, targetDir string) (*TreeLock, error) {
+	data, err := fsys.ReadFile(LockPath(targetDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return &TreeLock{Version: 1, Files: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+	lock := &TreeLock{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("invalid lock '%s': %w", LockPath(targetDir), err)
+	}
+	if lock.Files == nil {
+		lock.Files = make(map[string]string)
+	}
+	// As cópias base ficam em caminhos derivados do lock, que não pode apontar para fora do destino
+	for entryPath := range lock.Files {
+		if entryPathEscapes(entryPath) {
+			return nil, fmt.Errorf("invalid lock '%s': path '%s' is outside the target", LockPath(targetDir), entryPath)
+		}
+	}
+	return lock, nil
+}
+
+// SaveTreeLock writes the lock of a target directory.
+func SaveTreeLock(fsys it.IFileSystem, targetDir string, lock *TreeLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	lockPath := LockPath(targetDir)
+	if err := fsys.MkdirAll(filepath.Dir(lockPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := fsys.WriteFile(lockPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write lock: %w", err)
+	}
+	return nil
+}
+
+// contentChecksum computes the checksum of a generated content, as "sha256:<hex>".
+func contentChecksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// updateTreeLock records the composition in the lock of the target: the generated contents (kept as the base
+// of the next upgrade) replace the locked ones and the removed paths leave the lock. Nothing is written for a
+// target without a lock when nothing was generated.
+func (tc *TreeComposer) updateTreeLock(fsys it.IFileSystem, generated map[string][]byte, removed []string) error {
+	target := tc.FileTree.ComposerTargetPath
+	lock, err := LoadTreeLock(fsys, target)
+	if err != nil {
+		return err
+	}
+	if len(lock.Files) == 0 && len(generated) == 0 {
+		return nil
+	}
+	lock.TreeFile = tc.FileTree.TreeFileSource
+	if abs, absErr := filepath.Abs(lock.TreeFile); lock.TreeFile != "" && absErr == nil {
+		lock.TreeFile = abs
+	}
+	lock.Templates = make([]TreeLockTemplate, 0, len(tc.Options.TemplatePacks))
+	for _, pack := range tc.Options.TemplatePacks {
+		source := pack.Source
+		if abs, absErr := filepath.Abs(source); utl.CheckFileExists(source) && absErr == nil {
+			source = abs
+		}
+		lock.Templates = append(lock.Templates, TreeLockTemplate{Source: source, Version: pack.Manifest.Version})
+	}
+	lock.Values = tc.FileTree.Values
+	lock.Features = tc.FileTree.Features
+	lock.Overlays = absLockPaths(tc.FileTree.Overlays)
+	lock.RuleFiles = absLockPaths(tc.FileTree.RuleFiles)
+	lock.Profiles = absLockPaths(tc.FileTree.Profiles)
+	lock.UpdatedAt = time.Now()
+	for entryPath, content := range generated {
+		basePath := lockBasePath(target, entryPath)
+		if err := fsys.MkdirAll(filepath.Dir(basePath), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+		if err := fsys.WriteFile(basePath, content, 0644); err != nil {
+			return fmt.Errorf("failed to record the content of '%s': %w", entryPath, err)
+		}
+		lock.Files[entryPath] = contentChecksum(content)
+	}
+	for _, entryPath := range removed {
+		delete(lock.Files, entryPath)
+		if err := fsys.Remove(lockBasePath(target, entryPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove the content of '%s': %w", entryPath, err)
+		}
+	}
+	return SaveTreeLock(fsys, target, lock)
+}
+
+// absLockPaths returns the given input files as absolute paths, so the lock is valid from any directory.
+func absLockPaths(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	absPaths := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		absPaths = append(absPaths, p)
+	}
+	return absPaths
+}
+
+// dropTreeLockPaths removes paths from the lock of a target directory, with their generated contents, as when
+// their composition is undone. The lock goes away with its last file.
+func dropTreeLockPaths(fsys it.IFileSystem, targetDir string, paths []string) error {
+	lock, err := LoadTreeLock(fsys, targetDir)
+	if err != nil {
+		return err
+	}
+	baseDir := filepath.Join(targetDir, StateDirName, LockBaseDirName)
+	dropped := 0
+	for _, entryPath := range paths {
+		if _, locked := lock.Files[entryPath]; !locked {
+			continue
+		}
+		delete(lock.Files, entryPath)
+		dropped++
+		basePath := lockBasePath(targetDir, entryPath)
+		if err := fsys.Remove(basePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove the content of '%s': %w", entryPath, err)
+		}
+		// Diretórios da base que ficaram vazios
+		for dir := filepath.Dir(basePath); dir != baseDir && strings.HasPrefix(dir, baseDir); dir = filepath.Dir(dir) {
+			if contents, err := fsys.ReadDir(dir); err != nil || len(contents) > 0 || fsys.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	if dropped == 0 {
+		return nil
+	}
+	if len(lock.Files) > 0 {
+		return SaveTreeLock(fsys, targetDir, lock)
+	}
+	if err := fsys.RemoveAll(baseDir); err != nil {
+		return fmt.Errorf("failed to remove the generated contents: %w", err)
+	}
+	if err := fsys.Remove(LockPath(targetDir)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock: %w", err)
+	}
+	return nil
+}
+
+// UpgradeTree applies the tree (usually rendered by a newer template) to a target directory composed before,
+// using its lock. The files never touched since their generation are replaced by the new content, the files
+// edited locally are merged line by line with it (three-way, from the content recorded in the lock) and the
+// changes that overlap are left between conflict markers and reported. The new entries are created (with
+// their hooks), the untouched files dropped by the template are removed and the lock is updated.
+func (tc *TreeComposer) UpgradeTree(options *TreeUpgradeOptions) (*TreeUpgradeReport, error) {
+	if options == nil {
+		options = &TreeUpgradeOptions{}
+	}
+	fsys := tc.Options.FileSystem()
+	target := tc.FileTree.ComposerTargetPath
+	lock, err := LoadTreeLock(fsys, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(lock.Files) == 0 {
+		return nil, fmt.Errorf("no generated files locked in '%s', compose it first", target)
+	}
+	report := &TreeUpgradeReport{Actions: make([]TreeUpgradeAction, 0), Conflicts: make([]string, 0)}
+	writes := make(map[string][]byte)    // Conteúdo a escrever no destino
+	generated := make(map[string][]byte) // Conteúdo gerado pelo novo template, base da próxima atualização
+	created := make(map[string]bool)     // Entradas criadas agora, para os hooks
+	inTree := make(map[string]bool)      // Arquivos da nova árvore
+	removed := make([]string, 0)         // Arquivos que deixam o lock
+	kept := make(map[string]bool)        // Arquivos apagados localmente, não recriados
+
+	for _, entry := range tc.FileTree.GetEntries() {
+		entryPath := entry.GetPath()
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !utl.CheckFileExistsIn(fsys, targetPath) {
+			created[entryPath] = true
+			if entry.GetType() == "file" {
+				inTree[entryPath] = true
+				if _, locked := lock.Files[entryPath]; locked {
+					delete(created, entryPath)
+					kept[entryPath] = true
+					report.Actions = append(report.Actions, TreeUpgradeAction{Action: "keep", Path: entryPath, Detail: "deleted locally"})
+					continue
+				}
+			}
+			report.Actions = append(report.Actions, TreeUpgradeAction{Action: "create", Path: entryPath, Detail: entry.GetType()})
+			continue
+		}
+		if entry.GetType() != "file" {
+			continue
+		}
+		inTree[entryPath] = true
+		content, err := tc.entryContent(entry)
+		if err != nil {
+			return nil, err
+		}
+		current, err := fsys.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", entryPath, err)
+		}
+		lockedChecksum, locked := lock.Files[entryPath]
+		currentChecksum, newChecksum := contentChecksum(current), contentChecksum(content)
+		switch {
+		case currentChecksum == newChecksum:
+			if lockedChecksum != newChecksum {
+				generated[entryPath] = content // Já tem o novo conteúdo
+			}
+		case !locked:
+			report.Actions = append(report.Actions, TreeUpgradeAction{Action: "keep", Path: entryPath, Detail: "not generated by the template"})
+		case newChecksum == lockedChecksum:
+			// O template não mudou: as edições locais ficam como estão
+		case currentChecksum == lockedChecksum:
+			writes[entryPath], generated[entryPath] = content, content
+			report.Actions = append(report.Actions, TreeUpgradeAction{Action: "update", Path: entryPath, Detail: "not edited locally"})
+		case bytes.IndexByte(current, 0) >= 0 || bytes.IndexByte(content, 0) >= 0:
+			report.Conflicts = append(report.Conflicts, entryPath)
+			report.Actions = append(report.Actions, TreeUpgradeAction{Action: "conflict", Path: entryPath, Detail: "binary file changed locally and by the template, kept the local version"})
+		default:
+			base, baseErr := fsys.ReadFile(lockBasePath(target, entryPath))
+			if baseErr != nil && !errors.Is(baseErr, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read the generated content of '%s': %w", entryPath, baseErr)
+			}
+			merged, conflicts := utl.MergeText(string(base), string(current), string(content), "local", "template")
+			writes[entryPath], generated[entryPath] = []byte(merged), content
+			if conflicts > 0 {
+				report.Conflicts = append(report.Conflicts, entryPath)
+				report.Actions = append(report.Actions, TreeUpgradeAction{Action: "conflict", Path: entryPath, Detail: fmt.Sprintf("%d conflicting changes between markers", conflicts)})
+			} else {
+				report.Actions = append(report.Actions, TreeUpgradeAction{Action: "merge", Path: entryPath, Detail: "local edits kept"})
+			}
+		}
+	}
+
+	// Arquivos gerados que o novo template não tem mais
+	for entryPath, lockedChecksum := range lock.Files {
+		if inTree[entryPath] {
+			continue
+		}
+		removed = append(removed, entryPath)
+		targetPath := filepath.Join(target, filepath.FromSlash(entryPath))
+		if !utl.CheckFileExistsIn(fsys, targetPath) {
+			continue
+		}
+		current, err := fsys.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", entryPath, err)
+		}
+		if contentChecksum(current) != lockedChecksum {
+			report.Actions = append(report.Actions, TreeUpgradeAction{Action: "keep", Path: entryPath, Detail: "dropped by the template, edited locally"})
+			continue
+		}
+		report.Actions = append(report.Actions, TreeUpgradeAction{Action: "remove", Path: entryPath, Detail: "dropped by the template"})
+		if !options.DryRun {
+			if err := fsys.Remove(targetPath); err != nil {
+				return nil, fmt.Errorf("failed to remove '%s': %w", entryPath, err)
+			}
+		}
+	}
+	sort.SliceStable(report.Actions, func(i, j int) bool { return report.Actions[i].Path < report.Actions[j].Path })
+	sort.Strings(report.Conflicts)
+	if options.DryRun {
+		for _, action := range report.Actions {
+			gl.Log("info", fmt.Sprintf("[dry-run] %s %s (%s)", action.Action, action.Path, action.Detail))
+		}
+		return report, nil
+	}
+
+	// Compõe o que falta, com os hooks só para as entradas criadas agora
+	tc.kept = kept
+	err = tc.makeTree(false, true)
+	tc.kept = nil
+	if err != nil {
+		return report, err
+	}
+	for entryPath, content := range writes {
+		if err := fsys.WriteFile(filepath.Join(target, filepath.FromSlash(entryPath)), content, 0644); err != nil {
+			return report, fmt.Errorf("failed to update '%s': %w", entryPath, err)
+		}
+	}
+	if err := tc.updateTreeLock(fsys, generated, removed); err != nil {
+		return report, fmt.Errorf("failed to update the lock: %w", err)
+	}
+	if !tc.Options.NoHooks {
+		if err := tc.runTreeHooks(func(entry it.IFileEntry) bool { return created[entry.GetPath()] }); err != nil {
+			return report, fmt.Errorf("failed to run hooks: %w", err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,144 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// composeTestTree composes a tree view with inline bodies in a filesystem, recording it in the lock.
+func composeTestTree(t *testing.T, fsys it.IFileSystem, target, view string, options *FileTreeOptions) *TreeComposer {
+	t.Helper()
+	ft := newEmptyFileTree(filepath.Join(target, "tree.txt"), target, false, nil, options)
+	if err := ft.ParseTreeText("tree.txt", view); err != nil {
+		t.Fatalf("ParseTreeText() error = %v", err)
+	}
+	return &TreeComposer{FileTree: ft, Options: &TreeComposerOptions{FS: fsys, Lock: true, NoJournal: true}}
+}
+
+func TestUpgradeTree(t *testing.T) {
+	const target = "/work/app"
+	oldView := strings.Join([]string{
+		"untouched.txt", "edited.txt", "conflicting.txt", "dropped.txt",
+		"---",
+		"==> untouched.txt <==", "one",
+		"==> edited.txt <==", "a", "b", "c",
+		"==> conflicting.txt <==", "generated",
+		"==> dropped.txt <==", "old",
+	}, "\n")
+	newView := strings.Join([]string{
+		"untouched.txt", "edited.txt", "conflicting.txt", "added.txt",
+		"---",
+		"==> untouched.txt <==", "two",
+		"==> edited.txt <==", "a", "b", "C",
+		"==> conflicting.txt <==", "template",
+		"==> added.txt <==", "new",
+	}, "\n")
+
+	fsys := utl.NewMemFileSystem()
+	if err := composeTestTree(t, fsys, target, oldView, nil).MakeTree(); err != nil {
+		t.Fatalf("MakeTree() error = %v", err)
+	}
+	for name, content := range map[string]string{"edited.txt": "A\nb\nc\n", "conflicting.txt": "local\n"} {
+		if err := fsys.WriteFile(filepath.Join(target, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := composeTestTree(t, fsys, target, newView, nil).UpgradeTree(nil)
+	if err != nil {
+		t.Fatalf("UpgradeTree() error = %v", err)
+	}
+	actions := make(map[string]string)
+	for _, action := range report.Actions {
+		actions[action.Path] = action.Action
+	}
+	wantActions := map[string]string{
+		"untouched.txt":   "update",
+		"edited.txt":      "merge",
+		"conflicting.txt": "conflict",
+		"dropped.txt":     "remove",
+		"added.txt":       "create",
+	}
+	for entryPath, want := range wantActions {
+		if actions[entryPath] != want {
+			t.Errorf("action for %s = %q, want %q", entryPath, actions[entryPath], want)
+		}
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0] != "conflicting.txt" {
+		t.Errorf("Conflicts = %v, want [conflicting.txt]", report.Conflicts)
+	}
+
+	wantFiles := map[string]string{
+		"untouched.txt":   "two\n",
+		"edited.txt":      "A\nb\nC\n",
+		"conflicting.txt": "<<<<<<< local\nlocal\n=======\ntemplate\n>>>>>>> template\n",
+		"added.txt":       "new\n",
+	}
+	for name, want := range wantFiles {
+		data, err := fsys.ReadFile(filepath.Join(target, name))
+		if err != nil {
+			t.Errorf("ReadFile(%s) error = %v", name, err)
+		} else if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	if utl.CheckFileExistsIn(fsys, filepath.Join(target, "dropped.txt")) {
+		t.Errorf("dropped.txt was not removed")
+	}
+
+	lock, err := LoadTreeLock(fsys, target)
+	if err != nil {
+		t.Fatalf("LoadTreeLock() error = %v", err)
+	}
+	if _, locked := lock.Files["dropped.txt"]; locked {
+		t.Errorf("dropped.txt is still locked")
+	}
+	if got, want := lock.Files["conflicting.txt"], contentChecksum([]byte("template\n")); got != want {
+		t.Errorf("locked checksum of conflicting.txt = %s, want %s", got, want)
+	}
+}
+
+func TestUpgradeTreeReplaysOverlays(t *testing.T) {
+	const target = "/work/app"
+	overlay := filepath.Join(t.TempDir(), "ov.txt")
+	if err := os.WriteFile(overlay, []byte("+ app/extra.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := utl.NewMemFileSystem()
+	if err := composeTestTree(t, fsys, target, "app/\n└── main.go", &FileTreeOptions{Overlays: []string{overlay}}).MakeTree(); err != nil {
+		t.Fatalf("MakeTree() error = %v", err)
+	}
+	lock, err := LoadTreeLock(fsys, target)
+	if err != nil {
+		t.Fatalf("LoadTreeLock() error = %v", err)
+	}
+	if len(lock.Overlays) != 1 || lock.Overlays[0] != overlay {
+		t.Fatalf("locked overlays = %v, want [%s]", lock.Overlays, overlay)
+	}
+
+	// A atualização com os overlays do lock mantém as entradas geradas por eles
+	report, err := composeTestTree(t, fsys, target, "app/\n└── main.go", &FileTreeOptions{Overlays: lock.Overlays}).UpgradeTree(nil)
+	if err != nil {
+		t.Fatalf("UpgradeTree() error = %v", err)
+	}
+	for _, action := range report.Actions {
+		if action.Action == "remove" {
+			t.Errorf("%s was removed by the upgrade", action.Path)
+		}
+	}
+	if !utl.CheckFileExistsIn(fsys, filepath.Join(target, "app", "extra.txt")) {
+		t.Errorf("app/extra.txt is missing")
+	}
+	if lock, err = LoadTreeLock(fsys, target); err != nil {
+		t.Fatalf("LoadTreeLock() error = %v", err)
+	}
+	if _, locked := lock.Files["app/extra.txt"]; !locked {
+		t.Errorf("app/extra.txt left the lock")
+	}
+}
@@ -0,0 +1,191 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+var (
+	// defineRegex matches `@define <name> [param param=default ...]`.
+	defineRegex = regexp.MustCompile(`^@define\s+([\w.-]+)(.*)$`)
+	// useRegex matches `@use <name> [key=value ...]`.
+	useRegex = regexp.MustCompile(`^@use\s+([\w.-]+)(.*)$`)
+)
+
+// treeMacro is a named subtree defined with `@define` and instantiated with `@use`.
+type treeMacro struct {
+	Name     string
+	Params   []string          // Parâmetros declarados, na ordem
+	Defaults map[string]string // Valores padrão dos parâmetros opcionais
+	Depth    int               // Profundidade da linha @define
+	Body     []treeLine        // Linhas da subárvore definida
+	Origin   treeLine          // Linha @define, usada nas mensagens de erro
+}
+
+// expandTreeMacros collects the `@define` blocks and replaces every `@use` line by the macro subtree,
+// rendered with the global values plus the per-instance arguments and placed at the depth of the `@use`.
+// The `if:`/`unless:` annotations of a `@use` line are carried onto the root lines of its instance.
+func expandTreeMacros(lines []treeLine, values map[string]any) ([]treeLine, error) {
+	macros := make(map[string]*treeMacro)
+	remaining := make([]treeLine, 0, len(lines))
+
+	// 1: Separa as definições do restante da árvore
+	var current *treeMacro
+	for _, line := range lines {
+		prefix := utl.TreeLinePrefix(line.Text)
+		text := strings.TrimSpace(strings.TrimPrefix(line.Text, prefix))
+		depth := utl.TreeLineDepth(prefix)
+		if current != nil {
+			if text == "" || depth > current.Depth {
+				current.Body = append(current.Body, line)
+				continue
+			}
+			current = nil
+		}
+		if matches := defineRegex.FindStringSubmatch(stripDirectiveComment(text)); matches != nil {
+			if _, exists := macros[matches[1]]; exists {
+				gl.Log("error", fmt.Sprintf("Macro '%s' redefined at %s", matches[1], line.Position()))
+				return nil, fmt.Errorf("macro '%s' redefined at %s", matches[1], line.Position())
+			}
+			current = &treeMacro{Name: matches[1], Defaults: make(map[string]string), Depth: depth, Origin: line}
+			for _, param := range splitDirectiveArgs(matches[2]) {
+				name, defaultValue, hasDefault := strings.Cut(param, "=")
+				current.Params = append(current.Params, name)
+				if hasDefault {
+					current.Defaults[name] = defaultValue
+				}
+			}
+			macros[current.Name] = current
+			continue
+		}
+		remaining = append(remaining, line)
+	}
+
+	if len(macros) == 0 {
+		return remaining, nil
+	}
+	return instantiateTreeMacros(remaining, macros, values, nil)
+}
+
+func instantiateTreeMacros(lines []treeLine, macros map[string]*treeMacro, values map[string]any, chain []string) ([]treeLine, error) {
+	expanded := make([]treeLine, 0, len(lines))
+	for _, line := range lines {
+		prefix := utl.TreeLinePrefix(line.Text)
+		matches := useRegex.FindStringSubmatch(stripDirectiveComment(strings.TrimSpace(strings.TrimPrefix(line.Text, prefix))))
+		if matches == nil {
+			expanded = append(expanded, line)
+			continue
+		}
+
+		macro, exists := macros[matches[1]]
+		if !exists {
+			gl.Log("error", fmt.Sprintf("Undefined macro '%s' at %s", matches[1], line.Position()))
+			return nil, fmt.Errorf("undefined macro '%s' at %s", matches[1], line.Position())
+		}
+		for _, used := range chain {
+			if used == macro.Name {
+				return nil, fmt.Errorf("recursive macro '%s' at %s", macro.Name, line.Position())
+			}
+		}
+
+		// Argumentos da instância sobrepõem os valores globais
+		args := make(map[string]any, len(values)+len(macro.Params))
+		for k, v := range values {
+			args[k] = v
+		}
+		for k, v := range macro.Defaults {
+			args[k] = v
+		}
+		for _, arg := range splitDirectiveArgs(matches[2]) {
+			key, value, found := strings.Cut(arg, "=")
+			if !found {
+				return nil, fmt.Errorf("invalid argument '%s' for macro '%s' at %s, expected k=v", arg, macro.Name, line.Position())
+			}
+			args[key] = value
+		}
+		for _, param := range macro.Params {
+			if _, ok := args[param]; !ok {
+				gl.Log("error", fmt.Sprintf("Missing argument '%s' for macro '%s' at %s", param, macro.Name, line.Position()))
+				return nil, fmt.Errorf("missing argument '%s' for macro '%s' at %s", param, macro.Name, line.Position())
+			}
+		}
+
+		// As condições do @use valem para as linhas raiz da instância
+		conditions := lineConditions(line.Text)
+		useDepth := utl.TreeLineDepth(prefix)
+		instance := make([]treeLine, 0, len(macro.Body))
+		for _, bodyLine := range macro.Body {
+			if strings.TrimSpace(bodyLine.Text) == "" {
+				continue
+			}
+			bodyPrefix := utl.TreeLinePrefix(bodyLine.Text)
+			text := strings.TrimPrefix(bodyLine.Text, bodyPrefix)
+			if utl.HasTemplateActions(text) {
+				rendered, err := utl.RenderTemplate(bodyLine.Position(), text, args)
+				if err != nil {
+					return nil, fmt.Errorf("failed to instantiate macro '%s' at %s: %w", macro.Name, line.Position(), err)
+				}
+				text = rendered
+			}
+			if utl.TreeLineDepth(bodyPrefix) == macro.Depth+1 {
+				text = addLineConditions(text, conditions)
+			}
+			meta := map[string]any{"macro": macro.Name}
+			for k, v := range bodyLine.Meta {
+				meta[k] = v
+			}
+			instance = append(instance, treeLine{
+				Text:   DrawTreePrefix(utl.TreeLineDepth(bodyPrefix)-macro.Depth-1+useDepth) + text,
+				Source: bodyLine.Source,
+				Number: bodyLine.Number,
+				Meta:   meta,
+			})
+		}
+
+		// Macros podem usar outras macros
+		nested, err := instantiateTreeMacros(instance, macros, values, append(chain, macro.Name))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, nested...)
+	}
+	return expanded, nil
+}
+
+// stripDirectiveComment removes the trailing "# ..." comment of a directive line.
+func stripDirectiveComment(text string) string {
+	if directive, _, found := strings.Cut(text, "#"); found {
+		return strings.TrimSpace(directive)
+	}
+	return text
+}
+
+// splitDirectiveArgs splits the arguments of a directive by spaces, keeping quoted values together.
+func splitDirectiveArgs(text string) []string {
+	args := make([]string, 0)
+	var current strings.Builder
+	var quote rune
+	for _, r := range text {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && (r == ' ' || r == '\t'):
+			if current.Len() > 0 {
+				args = append(args, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		args = append(args, current.String())
+	}
+	return args
+}
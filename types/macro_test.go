@@ -0,0 +1,45 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMacroConditions(t *testing.T) {
+	define := []string{"@define svc name", "└── {{.name}}/", "    └── handler.go"}
+	tests := []struct {
+		name     string
+		tree     []string
+		features []string
+		paths    []string
+	}{
+		{
+			name:  "if: without the feature",
+			tree:  append(append([]string{}, define...), "app/", "├── @use svc name=orders", "└── @use svc name=users  # if: users"),
+			paths: []string{"app", "app/orders", "app/orders/handler.go"},
+		},
+		{
+			name:     "if: with the feature",
+			tree:     append(append([]string{}, define...), "app/", "├── @use svc name=orders", "└── @use svc name=users  # if: users"),
+			features: []string{"users"},
+			paths:    []string{"app", "app/orders", "app/orders/handler.go", "app/users", "app/users/handler.go"},
+		},
+		{
+			name:     "unless: with the feature",
+			tree:     append(append([]string{}, define...), "app/", "└── @use svc name=users  # unless: users"),
+			features: []string{"users"},
+			paths:    []string{"app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft, err := parseTestTreeFiles(t, map[string][]string{"tree.txt": tt.tree}, tt.features...)
+			if err != nil {
+				t.Fatalf("ParseTreeText() error = %v", err)
+			}
+			if got := treePaths(ft); !reflect.DeepEqual(got, tt.paths) {
+				t.Errorf("paths = %v, want %v", got, tt.paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+)
+
+var (
+	// fenceTitleRegex matches the path attribute in the info string of a fence: ```go title="cmd/main.go"
+	fenceTitleRegex = regexp.MustCompile(`(?:^|\s)(?:title|file|filename|path)=(?:"([^"]+)"|'([^']+)'|(\S+))`)
+	// fenceLabelRegex matches the lines labelling the next fence: **cmd/main.go**, `cmd/main.go` or ### cmd/main.go
+	fenceLabelRegex = regexp.MustCompile("^(?:#{1,6}\\s+)?(?:\\*\\*|__)?`?([^\\s`*]+?)`?(?:\\*\\*|__)?:?$")
+)
+
+// MarkdownFence is a fenced code block of a Markdown document.
+type MarkdownFence struct {
+	Info    string `json:"info" yaml:"info" xml:"info" toml:"info"`             // Info string da cerca (linguagem e atributos)
+	Path    string `json:"path" yaml:"path" xml:"path" toml:"path"`             // Caminho rotulado para o bloco, se houver
+	Content string `json:"content" yaml:"content" xml:"content" toml:"content"` // Conteúdo do bloco
+	Line    int    `json:"line" yaml:"line" xml:"line" toml:"line"`             // Linha de abertura da cerca
+}
+
+// MarkdownImportReport lists what could not be matched while importing a Markdown document.
+type MarkdownImportReport struct {
+	Matched          int             `json:"matched" yaml:"matched" xml:"matched" toml:"matched"`                                     // Blocos associados a entradas
+	UnmatchedFences  []MarkdownFence `json:"unmatchedFences" yaml:"unmatchedFences" xml:"unmatchedFences" toml:"unmatchedFences"`     // Blocos rotulados sem entrada na árvore
+	FilesWithoutBody []string        `json:"filesWithoutBody" yaml:"filesWithoutBody" xml:"filesWithoutBody" toml:"filesWithoutBody"` // Arquivos da árvore sem conteúdo
+}
+
+// ParseMarkdownFences returns the fenced code blocks of a Markdown document, with the path labelling each one:
+// a `title=` (or `file=`, `filename=`, `path=`) attribute of the fence, or the line right before it when it is
+// only a path in bold, in backticks or as a heading.
+func ParseMarkdownFences(markdown string) []MarkdownFence {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	fences := make([]MarkdownFence, 0)
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		marker := fenceMarker(trimmed)
+		if marker == "" {
+			continue
+		}
+		fence := MarkdownFence{Info: strings.TrimSpace(trimmed[len(marker):]), Line: i + 1}
+		if matches := fenceTitleRegex.FindStringSubmatch(fence.Info); matches != nil {
+			fence.Path = matches[1] + matches[2] + matches[3]
+		} else if label := previousLabel(lines, i); label != "" {
+			fence.Path = label
+		}
+
+		indent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " "))]
+		content := make([]string, 0)
+		for i++; i < len(lines); i++ {
+			closing := strings.TrimSpace(lines[i])
+			if strings.HasPrefix(closing, marker) && strings.Trim(closing, marker[:1]) == "" {
+				break
+			}
+			content = append(content, strings.TrimPrefix(lines[i], indent))
+		}
+		fence.Content = strings.Join(content, "\n")
+		if len(content) > 0 {
+			fence.Content += "\n"
+		}
+		fences = append(fences, fence)
+	}
+	return fences
+}
+
+// fenceMarker returns the opening marker (``` or ~~~, possibly longer) of a fence line.
+func fenceMarker(line string) string {
+	for _, char := range []string{"`", "~"} {
+		marker := line[:len(line)-len(strings.TrimLeft(line, char))]
+		if len(marker) >= 3 {
+			return marker
+		}
+	}
+	return ""
+}
+
+// previousLabel returns the path labelling a fence in the nearest non blank line before it.
+func previousLabel(lines []string, fenceIndex int) string {
+	for i := fenceIndex - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		matches := fenceLabelRegex.FindStringSubmatch(line)
+		if matches == nil || !strings.ContainsAny(matches[1], "./") {
+			return ""
+		}
+		return matches[1]
+	}
+	return ""
+}
+
+// isTreeFence reports if a fence holds a tree view.
+func isTreeFence(fence MarkdownFence) bool {
+	return fence.Path == "" && (strings.Contains(fence.Content, "├──") || strings.Contains(fence.Content, "└──"))
+}
+
+// ImportMarkdownTree builds a FileTree from a Markdown document: the first fence holding a tree view is parsed,
+// and the fences labelled with a path become the bodies of the matching entries. The report lists the labelled
+// fences that match no entry and the files that got no content.
+func ImportMarkdownTree(markdownPath, composerTargetPath string, options *FileTreeOptions) (it.IFileTree, *MarkdownImportReport, error) {
+	data, err := os.ReadFile(markdownPath)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read markdown file: %s", err))
+		return nil, nil, fmt.Errorf("failed to read markdown file '%s': %s", markdownPath, err)
+	}
+	absSource, err := filepath.Abs(markdownPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if composerTargetPath != "" {
+		if composerTargetPath, err = filepath.Abs(composerTargetPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fences := ParseMarkdownFences(string(data))
+	var treeFence *MarkdownFence
+	for i := range fences {
+		if isTreeFence(fences[i]) {
+			treeFence = &fences[i]
+			break
+		}
+	}
+	if treeFence == nil {
+		gl.Log("error", fmt.Sprintf("No tree view found in markdown file: %s", markdownPath))
+		return nil, nil, fmt.Errorf("no tree view found in markdown file '%s'", markdownPath)
+	}
+
+	ft := newEmptyFileTree(absSource, composerTargetPath, false, nil, options)
+	if err := ft.ParseTreeText(absSource, treeFence.Content); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the tree view at line %d: %w", treeFence.Line, err)
+	}
+
+	report := &MarkdownImportReport{UnmatchedFences: make([]MarkdownFence, 0), FilesWithoutBody: make([]string, 0)}
+	for _, fence := range fences {
+		if fence.Path == "" {
+			continue
+		}
+		entry := ft.GetEntryByPath(resolveOverlayPath(ft, fence.Path, false))
+		if entry == nil || entry.GetType() == "directory" {
+			report.UnmatchedFences = append(report.UnmatchedFences, fence)
+			continue
+		}
+		ft.SetEntryBody(entry, fence.Content)
+		report.Matched++
+	}
+	for _, entry := range ft.GetEntries() {
+		if entry.GetType() != "file" {
+			continue
+		}
+		if _, ok := ft.EntryBody(entry); !ok {
+			report.FilesWithoutBody = append(report.FilesWithoutBody, entry.GetPath())
+		}
+	}
+	return ft, report, nil
+}
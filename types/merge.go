@@ -0,0 +1,575 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// TreeMergeConflict is a change made differently by both sides of a merge. The merged tree keeps the version of
+// ours (or of the side that did not remove the entry).
+type TreeMergeConflict struct {
+	Kind   string `json:"kind" yaml:"kind" xml:"kind" toml:"kind"`                                     // "remove", "rename", "move", "comment", "metadata", "add" ou "path"
+	Path   string `json:"path" yaml:"path" xml:"path" toml:"path"`                                     // Caminho na árvore mesclada
+	Key    string `json:"key,omitempty" yaml:"key,omitempty" xml:"key,omitempty" toml:"key,omitempty"` // Chave dos metadados em conflito
+	Ours   string `json:"ours" yaml:"ours" xml:"ours" toml:"ours"`                                     // Versão do nosso lado
+	Theirs string `json:"theirs" yaml:"theirs" xml:"theirs" toml:"theirs"`                             // Versão do outro lado
+}
+
+// TreeMerge is the outcome of a three-way merge of trees.
+type TreeMerge struct {
+	Tree      *FileTree           // Árvore mesclada
+	Conflicts []TreeMergeConflict // Conflitos, pelo caminho
+	// Linhas das duas versões das entradas em conflito, desenhadas entre os marcadores
+	alternatives map[uuid.UUID][2]string
+}
+
+// HasConflicts reports if the merge left conflicts to resolve.
+func (m *TreeMerge) HasConflicts() bool {
+	return len(m.Conflicts) > 0
+}
+
+// mergeNode is an entry of the merged tree while it is built: an entry of the base (matched in ours and theirs
+// by DiffTrees) or an entry added by one of the sides.
+type mergeNode struct {
+	key     string        // "b:", "o:" ou "t:" seguido do ID da entrada
+	name    string        // Nome mesclado
+	kind    string        // Tipo da entrada
+	parent  string        // Chave do pai ("" na raiz)
+	comment string        // Comentário mesclado
+	meta    utl.JsonB     // Metadados mesclados
+	removed bool          // Removida da árvore mesclada
+	base    it.IFileEntry // Versões da entrada (nil quando ausente)
+	ours    it.IFileEntry
+	theirs  it.IFileEntry
+	// Conflitos da entrada, resolvidos pela versão de ours
+	conflicts []TreeMergeConflict
+	rival     *mergeNode // Entrada descartada por cair no mesmo caminho
+}
+
+// LoadTreeSource reads a tree file as written, to be merged: the templates are not rendered, the `# if:` and
+// `# unless:` conditions are kept in the comments instead of evaluated, and the `@rule` and `@hook` lines are
+// kept apart in the Rules and Hooks of the tree. The directives that can't be kept in the entries (`@include`,
+// `@define`/`@use` and the inline bodies) and the templates in the names are refused.
+func LoadTreeSource(treeFile string) (*FileTree, error) {
+	absSource, err := filepath.Abs(treeFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(absSource)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read tree file: %s", err))
+		return nil, fmt.Errorf("failed to read tree file: %s", err)
+	}
+	lines := make([]treeLine, 0)
+	for i, text := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		lines = append(lines, treeLine{Text: text, Source: treeFile, Number: i + 1})
+	}
+	lines, bodies, err := extractTreeBodies(lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(bodies) > 0 {
+		return nil, fmt.Errorf("%s: inline bodies can't be merged", treeFile)
+	}
+	ft := newEmptyFileTree(absSource, ".", false, nil, nil)
+	if lines, ft.Rules, err = extractTreeRules(lines); err != nil {
+		return nil, fmt.Errorf("failed to parse tree rules: %s", err)
+	}
+	if lines, ft.Hooks, err = extractTreeHooks(lines); err != nil {
+		return nil, fmt.Errorf("failed to parse tree hooks: %s", err)
+	}
+	for _, line := range lines {
+		text := strings.TrimSpace(strings.TrimPrefix(line.Text, utl.TreeLinePrefix(line.Text)))
+		if strings.HasPrefix(text, "<<<<<<<") || text == "=======" || strings.HasPrefix(text, ">>>>>>>") {
+			return nil, fmt.Errorf("unresolved merge conflict at %s", line.Position())
+		}
+		if directive, _, _ := strings.Cut(text, " "); directive == "@include" || directive == "@define" || directive == "@use" {
+			return nil, fmt.Errorf("%s: %s lines can't be merged, merge the files they expand instead", line.Position(), directive)
+		}
+		if name, _ := utl.ExtractComment(text); utl.HasTemplateActions(name) {
+			return nil, fmt.Errorf("%s: names with templates can't be merged", line.Position())
+		}
+		if text == "" {
+			continue
+		}
+		entry, err := ParseFieldsFromTreeView(line.Text, ft)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse line '%s': %s", line.Text, err)
+		}
+		if entry != nil {
+			ft.AddEntry(entry)
+		}
+	}
+	if err := utl.SetTreeViewEntriesDeepness(ft); err != nil {
+		return nil, fmt.Errorf("failed to set tree view entries deepness: %s", err)
+	}
+	return ft, nil
+}
+
+// MergeTrees merges the changes made to a base tree by two sides (ours and theirs). Entries are matched with
+// DiffTrees, so additions, removals, moves, renames and comment or metadata changes made by a single side (or
+// equally by both) merge on their own. Changes made differently by both sides, removals of entries changed by
+// the other side and different entries landing on the same path are conflicts: the merged tree keeps the version
+// of ours and Render draws both versions between conflict markers.
+func MergeTrees(baseTree, oursTree, theirsTree it.IFileTree) (*TreeMerge, error) {
+	oursDiff, err := DiffTrees(baseTree, oursTree)
+	if err != nil {
+		return nil, err
+	}
+	theirsDiff, err := DiffTrees(baseTree, theirsTree)
+	if err != nil {
+		return nil, err
+	}
+	base := oursDiff.Old
+
+	nodes := make(map[string]*mergeNode)
+	order := make([]*mergeNode, 0)
+	add := func(node *mergeNode) {
+		nodes[node.key] = node
+		order = append(order, node)
+	}
+	// Chave do nó de uma entrada de um dos lados
+	oursKeys := make(map[uuid.UUID]string)
+	theirsKeys := make(map[uuid.UUID]string)
+	for _, entry := range base.GetEntries() {
+		key := "b:" + entry.GetID().String()
+		if counterpart, ok := oursDiff.matches[entry.GetID()]; ok {
+			oursKeys[counterpart.GetID()] = key
+		}
+		if counterpart, ok := theirsDiff.matches[entry.GetID()]; ok {
+			theirsKeys[counterpart.GetID()] = key
+		}
+	}
+	for _, entry := range oursDiff.New.GetEntries() {
+		if _, ok := oursKeys[entry.GetID()]; !ok {
+			oursKeys[entry.GetID()] = "o:" + entry.GetID().String()
+		}
+	}
+	for _, entry := range theirsDiff.New.GetEntries() {
+		if _, ok := theirsKeys[entry.GetID()]; !ok {
+			theirsKeys[entry.GetID()] = "t:" + entry.GetID().String()
+		}
+	}
+	parentKey := func(ft *FileTree, keys map[uuid.UUID]string, entry it.IFileEntry) string {
+		if parent := treeParent(ft, entry); parent != nil {
+			return keys[parent.GetID()]
+		}
+		return ""
+	}
+
+	for _, entry := range base.GetEntries() {
+		node := &mergeNode{key: "b:" + entry.GetID().String(), kind: entry.GetType(), base: entry, ours: oursDiff.matches[entry.GetID()], theirs: theirsDiff.matches[entry.GetID()]}
+		add(node)
+		baseParent := ""
+		if parent := treeParent(base, entry); parent != nil {
+			baseParent = "b:" + parent.GetID().String()
+		}
+		switch {
+		case node.ours == nil && node.theirs == nil:
+			node.removed = true
+		case node.ours == nil || node.theirs == nil:
+			// Removida de um lado: a remoção vale se o outro lado não mudou a entrada
+			kept, keys, ft, side := node.theirs, theirsKeys, theirsDiff.New, "theirs"
+			if node.theirs == nil {
+				kept, keys, ft, side = node.ours, oursKeys, oursDiff.New, "ours"
+			}
+			node.name, node.parent = kept.GetName(), parentKey(ft, keys, kept)
+			node.comment, node.meta = strings.TrimSpace(EntryComments(kept)), mergeableMetadata(kept)
+			if node.name == entry.GetName() && node.parent == baseParent && node.comment == strings.TrimSpace(EntryComments(entry)) && sameMetadata(node.meta, mergeableMetadata(entry)) {
+				node.removed = true
+			} else {
+				node.conflicts = append(node.conflicts, removalConflict(side))
+			}
+		default:
+			node.name = mergeNodeValue(node, "rename", "", entry.GetName(), node.ours.GetName(), node.theirs.GetName())
+			node.parent = mergeNodeValue(node, "move", "", baseParent, parentKey(oursDiff.New, oursKeys, node.ours), parentKey(theirsDiff.New, theirsKeys, node.theirs))
+			node.comment = mergeNodeValue(node, "comment", "", strings.TrimSpace(EntryComments(entry)), strings.TrimSpace(EntryComments(node.ours)), strings.TrimSpace(EntryComments(node.theirs)))
+			node.meta = mergeNodeMetadata(node, mergeableMetadata(entry), mergeableMetadata(node.ours), mergeableMetadata(node.theirs))
+		}
+	}
+	for _, side := range []struct {
+		diff *TreeDiff
+		keys map[uuid.UUID]string
+		name string
+	}{{oursDiff, oursKeys, "ours"}, {theirsDiff, theirsKeys, "theirs"}} {
+		for _, entry := range side.diff.New.GetEntries() {
+			key := side.keys[entry.GetID()]
+			if !strings.HasPrefix(key, side.name[:1]+":") {
+				continue
+			}
+			node := &mergeNode{key: key, name: entry.GetName(), kind: entry.GetType(), parent: parentKey(side.diff.New, side.keys, entry),
+				comment: strings.TrimSpace(EntryComments(entry)), meta: mergeableMetadata(entry)}
+			if side.name == "ours" {
+				node.ours = entry
+			} else {
+				node.theirs = entry
+			}
+			add(node)
+		}
+	}
+
+	// Uma entrada mantida mantém os seus ancestrais, mesmo que um lado os tenha removido
+	for _, node := range order {
+		if node.removed {
+			continue
+		}
+		for parent := nodes[node.parent]; parent != nil && parent.removed; parent = nodes[parent.parent] {
+			parent.removed = false
+			source, side := parent.ours, "ours"
+			if source == nil {
+				source, side = parent.theirs, "theirs"
+			}
+			if source == nil {
+				source, side = parent.base, "ours"
+			}
+			parent.name, parent.comment, parent.meta = source.GetName(), strings.TrimSpace(EntryComments(source)), mergeableMetadata(source)
+			if parent.parent == "" {
+				parent.parent = baseParentKey(base, parent)
+			}
+			parent.conflicts = append(parent.conflicts, removalConflict(side))
+		}
+	}
+
+	// Caminhos mesclados, dos nós mais rasos para os mais profundos
+	aliases := make(map[string]string) // Nós unidos a outro nó com o mesmo caminho
+	resolve := func(key string) string {
+		for aliases[key] != "" {
+			key = aliases[key]
+		}
+		return key
+	}
+	depth := func(node *mergeNode) int {
+		for {
+			d := 0
+			seen := map[string]bool{node.key: true}
+			var cycle *mergeNode
+			for parent := nodes[node.parent]; parent != nil; parent = nodes[parent.parent] {
+				if seen[parent.key] {
+					cycle = parent
+					break
+				}
+				seen[parent.key] = true
+				d++
+			}
+			if cycle == nil {
+				return d
+			}
+			// Os lados moveram diretórios um para dentro do outro: o nó do ciclo volta para o pai da base
+			cycle.parent = baseParentKey(base, cycle)
+			cycle.conflicts = append(cycle.conflicts, TreeMergeConflict{Kind: "move", Ours: "moved", Theirs: "moved elsewhere"})
+		}
+	}
+	live := make([]*mergeNode, 0)
+	for _, node := range order {
+		if !node.removed {
+			live = append(live, node)
+		}
+	}
+	depths := make(map[string]int)
+	for _, node := range live {
+		depths[node.key] = depth(node)
+	}
+	sort.SliceStable(live, func(i, j int) bool { return depths[live[i].key] < depths[live[j].key] })
+
+	paths := make(map[string]string)
+	byPath := make(map[string]*mergeNode)
+	dropped := make(map[string]bool)
+	for _, node := range live {
+		parent := resolve(node.parent)
+		if parent != "" && (dropped[parent] || paths[parent] == "") {
+			dropped[node.key] = true // Descendente de um nó descartado
+			continue
+		}
+		nodePath := node.name
+		if parent != "" {
+			nodePath = paths[parent] + "/" + node.name
+		}
+		existing, taken := byPath[nodePath]
+		if !taken {
+			paths[node.key], byPath[nodePath] = nodePath, node
+			continue
+		}
+		// Duas entradas no mesmo caminho: diretórios (ou adições iguais) se unem, o resto é conflito
+		added := existing.base == nil || node.base == nil
+		if existing.kind == node.kind && added && (node.kind == "directory" || (existing.comment == node.comment && sameMetadata(existing.meta, node.meta))) {
+			aliases[node.key] = existing.key
+			continue
+		}
+		kind := "path"
+		if existing.base == nil && node.base == nil {
+			kind = "add"
+		}
+		existing.conflicts = append(existing.conflicts, TreeMergeConflict{Kind: kind, Ours: describeMergeNode(existing), Theirs: describeMergeNode(node)})
+		existing.rival = node
+		dropped[node.key] = true
+	}
+
+	// Monta a árvore mesclada, na ordem dos irmãos
+	merged := newEmptyFileTree(oursDiff.New.TreeFileSource, oursDiff.New.ComposerTargetPath, false, nil, nil)
+	merged.Rules = mergeTreeDirectives(base.Rules, oursDiff.New.Rules, theirsDiff.New.Rules)
+	merged.Hooks = mergeTreeDirectives(base.Hooks, oursDiff.New.Hooks, theirsDiff.New.Hooks)
+	result := &TreeMerge{Tree: merged, Conflicts: make([]TreeMergeConflict, 0), alternatives: make(map[uuid.UUID][2]string)}
+	// Os irmãos seguem a ordem de ours, e as entradas que só theirs tem seguem a entrada que as precede lá
+	position := make(map[string]float64)
+	for i, entry := range oursDiff.New.GetEntries() {
+		position[oursKeys[entry.GetID()]] = float64(i)
+	}
+	anchor, offset, step := -1.0, 0.0, 1/float64(len(theirsDiff.New.GetEntries())+1)
+	for _, entry := range theirsDiff.New.GetEntries() {
+		key := theirsKeys[entry.GetID()]
+		if pos, ok := position[key]; ok {
+			anchor, offset = pos, 0
+			continue
+		}
+		offset += step
+		position[key] = anchor + offset
+	}
+	for i, node := range order {
+		if _, ok := position[node.key]; !ok {
+			position[node.key] = float64(len(oursDiff.New.GetEntries()) + i)
+		}
+	}
+	children := make(map[string][]*mergeNode)
+	for _, node := range live {
+		if dropped[node.key] || aliases[node.key] != "" {
+			continue
+		}
+		children[resolve(node.parent)] = append(children[resolve(node.parent)], node)
+	}
+	var build func(parent string) error
+	build = func(parent string) error {
+		siblings := children[parent]
+		sort.SliceStable(siblings, func(i, j int) bool { return position[siblings[i].key] < position[siblings[j].key] })
+		for _, node := range siblings {
+			entry, err := merged.AddEntryByPath(paths[node.key], node.kind, node.comment)
+			if err != nil {
+				return fmt.Errorf("failed to add merged entry '%s': %w", paths[node.key], err)
+			}
+			annotations := make(map[string]any, len(node.meta))
+			for key, value := range node.meta {
+				annotations[key] = value
+			}
+			ApplyEntryAnnotations(entry, annotations)
+			if len(node.conflicts) > 0 {
+				for _, conflict := range node.conflicts {
+					conflict.Path = paths[node.key]
+					result.Conflicts = append(result.Conflicts, conflict)
+				}
+				ours, theirs := node.ours, node.theirs
+				if node.rival != nil {
+					// Os dois lados são as duas entradas que caíram no mesmo caminho
+					ours, theirs = firstEntry(node.ours, node.theirs), firstEntry(node.rival.theirs, node.rival.ours)
+				}
+				result.alternatives[entry.GetID()] = [2]string{mergeAlternative(node, ours, paths), mergeAlternative(node, theirs, paths)}
+			}
+			if err := build(node.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := build(""); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(result.Conflicts, func(i, j int) bool { return result.Conflicts[i].Path < result.Conflicts[j].Path })
+	return result, nil
+}
+
+// Render draws the merged tree, after its `@rule` and `@hook` lines. Each entry in conflict is drawn twice, as
+// in ours and as in theirs, between `<<<<<<< ours`, `=======` and `>>>>>>> theirs` lines (an empty side was
+// removed), so the drawing can only be parsed back once the conflicts are resolved by hand.
+func (m *TreeMerge) Render() string {
+	var sb strings.Builder
+	for _, directive := range append(append([]string{}, m.Tree.Rules...), m.Tree.Hooks...) {
+		sb.WriteString(directive + "\n")
+	}
+	var renderLevel func(entries []it.IFileEntry, prefix string, depth int)
+	renderLevel = func(entries []it.IFileEntry, prefix string, depth int) {
+		for i, entry := range entries {
+			branch, continuation := "├── ", "│   "
+			if i == len(entries)-1 {
+				branch, continuation = "└── ", "    "
+			}
+			if depth == 0 {
+				branch, continuation = "", ""
+			}
+			if alternatives, conflicted := m.alternatives[entry.GetID()]; conflicted {
+				sb.WriteString("<<<<<<< ours\n")
+				if alternatives[0] != "" {
+					sb.WriteString(prefix + branch + alternatives[0] + "\n")
+				}
+				sb.WriteString("=======\n")
+				if alternatives[1] != "" {
+					sb.WriteString(prefix + branch + alternatives[1] + "\n")
+				}
+				sb.WriteString(">>>>>>> theirs\n")
+			} else {
+				sb.WriteString(prefix + branch + renderEntryLine(entry, &TreeRenderOptions{Comments: true}) + "\n")
+			}
+			if entry.GetType() == "directory" {
+				renderLevel(m.Tree.GetChildren(entry.GetID()), prefix+continuation, depth+1)
+			}
+		}
+	}
+	renderLevel(m.Tree.GetChildren(uuid.Nil), "", 0)
+	return sb.String()
+}
+
+// mergeNodeValue merges a value changed by the sides: a change made by a single side (or equally by both)
+// wins, different changes are a conflict resolved by ours.
+func mergeNodeValue(node *mergeNode, kind, key, base, ours, theirs string) string {
+	switch {
+	case ours == theirs || theirs == base:
+		return ours
+	case ours == base:
+		return theirs
+	}
+	conflict := TreeMergeConflict{Kind: kind, Key: key, Ours: ours, Theirs: theirs}
+	if kind == "move" {
+		conflict.Ours, conflict.Theirs = "moved", "moved elsewhere"
+	}
+	node.conflicts = append(node.conflicts, conflict)
+	return ours
+}
+
+// mergeNodeMetadata merges the metadata of an entry key by key.
+func mergeNodeMetadata(node *mergeNode, base, ours, theirs utl.JsonB) utl.JsonB {
+	keys := make(map[string]bool)
+	for _, values := range []utl.JsonB{base, ours, theirs} {
+		for key := range values {
+			keys[key] = true
+		}
+	}
+	encode := func(values utl.JsonB, key string) string {
+		value, ok := values[key]
+		if !ok {
+			return ""
+		}
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	merged := utl.JsonB{}
+	for key := range keys {
+		value := mergeNodeValue(node, "metadata", key, encode(base, key), encode(ours, key), encode(theirs, key))
+		if value == "" {
+			continue // Removida
+		}
+		var decoded any
+		_ = json.Unmarshal([]byte(value), &decoded)
+		merged[key] = decoded
+	}
+	// Os conflitos de metadados mostram os valores, não a serialização
+	for i, conflict := range node.conflicts {
+		if conflict.Kind == "metadata" {
+			node.conflicts[i].Ours, node.conflicts[i].Theirs = describeMetadataValue(ours, conflict.Key), describeMetadataValue(theirs, conflict.Key)
+		}
+	}
+	return merged
+}
+
+// describeMetadataValue formats a metadata value as an annotation value ("(none)" when missing).
+func describeMetadataValue(values utl.JsonB, key string) string {
+	if value, ok := values[key]; ok {
+		return formatAnnotationValue(value)
+	}
+	return "(none)"
+}
+
+// mergeableMetadata returns the metadata of an entry merged by MergeTrees, without the bookkeeping keys.
+func mergeableMetadata(entry it.IFileEntry) utl.JsonB {
+	values := utl.JsonB{}
+	for key, value := range EntryMetadata(entry) {
+		if !treeDiffSkippedKeys[key] {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// sameMetadata reports if two sets of metadata serialize the same.
+func sameMetadata(a, b utl.JsonB) bool {
+	dataA, _ := json.Marshal(a)
+	dataB, _ := json.Marshal(b)
+	return string(dataA) == string(dataB)
+}
+
+// baseParentKey returns the key of the parent of a node in the base ("" for the top level and added nodes).
+func baseParentKey(base *FileTree, node *mergeNode) string {
+	if node.base != nil {
+		if parent := treeParent(base, node.base); parent != nil {
+			return "b:" + parent.GetID().String()
+		}
+	}
+	return ""
+}
+
+// firstEntry returns the first entry that is not nil.
+func firstEntry(entries ...it.IFileEntry) it.IFileEntry {
+	for _, entry := range entries {
+		if entry != nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+// removalConflict is the conflict of an entry removed by a side and changed by the other.
+func removalConflict(changedBy string) TreeMergeConflict {
+	if changedBy == "ours" {
+		return TreeMergeConflict{Kind: "remove", Ours: "changed", Theirs: "removed"}
+	}
+	return TreeMergeConflict{Kind: "remove", Ours: "removed", Theirs: "changed"}
+}
+
+// describeMergeNode describes an entry of the merge in a conflict.
+func describeMergeNode(node *mergeNode) string {
+	if node.kind == "directory" {
+		return node.name + "/"
+	}
+	return node.name + " (" + node.kind + ")"
+}
+
+// mergeAlternative draws the line of a side of an entry in conflict, noting where the side put the entry when
+// it is not where the merged tree has it. An entry missing on the side is drawn as an empty line.
+func mergeAlternative(node *mergeNode, entry it.IFileEntry, paths map[string]string) string {
+	if entry == nil {
+		return ""
+	}
+	line := renderEntryLine(entry, &TreeRenderOptions{Comments: true})
+	if dir := path.Dir(entry.GetPath()); dir != path.Dir(paths[node.key]) {
+		if dir == "." {
+			dir = "the top level"
+		}
+		line += "  (in " + dir + ")"
+	}
+	return line
+}
+
+// mergeTreeDirectives merges the `@rule` or `@hook` lines: the lines removed by a side are removed, and the
+// lines added by theirs follow the ones of ours.
+func mergeTreeDirectives(base, ours, theirs []string) []string {
+	baseSet, oursSet, theirsSet := toSet(base), toSet(ours), toSet(theirs)
+	merged := make([]string, 0, len(ours))
+	for _, hook := range ours {
+		if !baseSet[hook] || theirsSet[hook] {
+			merged = append(merged, hook)
+		}
+	}
+	for _, hook := range theirs {
+		if !baseSet[hook] && !oursSet[hook] {
+			merged = append(merged, hook)
+		}
+	}
+	return merged
+}
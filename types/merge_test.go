@@ -0,0 +1,119 @@
+package types
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parseTestTree parses a tree view held in memory.
+func parseTestTree(t *testing.T, view string) *FileTree {
+	t.Helper()
+	ft := newEmptyFileTree("tree.txt", ".", false, nil, nil)
+	if err := ft.ParseTreeText("tree.txt", view); err != nil {
+		t.Fatalf("ParseTreeText() error = %v", err)
+	}
+	return ft
+}
+
+// treePaths returns the sorted paths of the entries of a tree.
+func treePaths(ft *FileTree) []string {
+	paths := make([]string, 0, len(ft.GetEntries()))
+	for _, entry := range ft.GetEntries() {
+		paths = append(paths, entry.GetPath())
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestMergeTrees(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      []string
+		ours      []string
+		theirs    []string
+		paths     []string
+		conflicts []string // Tipo e caminho dos conflitos
+		comment   map[string]string
+	}{
+		{
+			name:   "move by ours and rename by theirs",
+			base:   []string{"app/", "├── a/", "│   └── x.go", "└── b/"},
+			ours:   []string{"app/", "├── a/", "└── b/", "    └── x.go"},
+			theirs: []string{"app/", "├── a/", "│   └── y.go", "└── b/"},
+			paths:  []string{"app", "app/a", "app/b", "app/b/y.go"},
+		},
+		{
+			name:   "rename by ours and move by theirs",
+			base:   []string{"app/", "├── a/", "│   └── x.go", "└── b/"},
+			ours:   []string{"app/", "├── a/", "│   └── y.go", "└── b/"},
+			theirs: []string{"app/", "├── a/", "└── b/", "    └── x.go"},
+			paths:  []string{"app", "app/a", "app/b", "app/b/y.go"},
+		},
+		{
+			name:   "removal of an unchanged entry",
+			base:   []string{"app/", "├── x.go  # old", "└── y.go"},
+			ours:   []string{"app/", "└── y.go"},
+			theirs: []string{"app/", "├── x.go  # old", "└── y.go  # changed"},
+			paths:  []string{"app", "app/y.go"},
+		},
+		{
+			name:      "removal against a change",
+			base:      []string{"app/", "├── x.go  # old", "└── y.go"},
+			ours:      []string{"app/", "└── y.go"},
+			theirs:    []string{"app/", "├── x.go  # new", "└── y.go"},
+			paths:     []string{"app", "app/x.go", "app/y.go"},
+			conflicts: []string{"remove app/x.go"},
+			comment:   map[string]string{"app/x.go": "new"},
+		},
+		{
+			name:      "removal of a directory against an addition into it",
+			base:      []string{"app/", "├── a/", "│   └── x.go", "└── y.go"},
+			ours:      []string{"app/", "└── y.go"},
+			theirs:    []string{"app/", "├── a/", "│   ├── x.go", "│   └── z.go", "└── y.go"},
+			paths:     []string{"app", "app/a", "app/a/z.go", "app/y.go"},
+			conflicts: []string{"remove app/a"},
+		},
+		{
+			name:      "different renames",
+			base:      []string{"app/", "└── x.go"},
+			ours:      []string{"app/", "└── y.go"},
+			theirs:    []string{"app/", "└── z.go"},
+			paths:     []string{"app", "app/y.go"},
+			conflicts: []string{"rename app/y.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := parseTestTree(t, strings.Join(tt.base, "\n"))
+			ours := parseTestTree(t, strings.Join(tt.ours, "\n"))
+			theirs := parseTestTree(t, strings.Join(tt.theirs, "\n"))
+			merge, err := MergeTrees(base, ours, theirs)
+			if err != nil {
+				t.Fatalf("MergeTrees() error = %v", err)
+			}
+			if got := treePaths(merge.Tree); !reflect.DeepEqual(got, tt.paths) {
+				t.Errorf("paths = %v, want %v", got, tt.paths)
+			}
+			conflicts := make([]string, 0)
+			for _, conflict := range merge.Conflicts {
+				conflicts = append(conflicts, conflict.Kind+" "+conflict.Path)
+			}
+			if want := append([]string{}, tt.conflicts...); !reflect.DeepEqual(conflicts, want) {
+				t.Errorf("conflicts = %v, want %v", conflicts, want)
+			}
+			for entryPath, want := range tt.comment {
+				entry := merge.Tree.GetEntryByPath(entryPath)
+				if entry == nil {
+					t.Errorf("%s is missing", entryPath)
+				} else if got := EntryComments(entry); got != want {
+					t.Errorf("comment of %s = %q, want %q", entryPath, got, want)
+				}
+			}
+			if got := strings.Contains(merge.Render(), "<<<<<<<"); got != merge.HasConflicts() {
+				t.Errorf("Render() has markers = %v, want %v", got, merge.HasConflicts())
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"encoding/json"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// EntryMetadata returns the metadata of an entry as a map, attaching an empty one when the entry has none.
+func EntryMetadata(entry it.IFileEntry) utl.JsonB {
+	if entry == nil {
+		return utl.JsonB{}
+	}
+	switch metadata := entry.GetMetadata().(type) {
+	case *utl.JsonB:
+		if metadata != nil && *metadata != nil {
+			return *metadata
+		}
+	case nil:
+	default:
+		// Outras implementações de IJsonB são convertidas pelo valor serializado
+		converted := utl.JsonB{}
+		if value, err := metadata.Value(); err == nil {
+			if data, ok := value.([]byte); ok {
+				_ = json.Unmarshal(data, &converted)
+			}
+		}
+		entry.SetMetadata(&converted)
+		return converted
+	}
+	metadata := utl.JsonB{}
+	entry.SetMetadata(&metadata)
+	return metadata
+}
+
+// GetEntryMetadataValue returns a single metadata value of an entry.
+func GetEntryMetadataValue(entry it.IFileEntry, key string) (any, bool) {
+	if entry == nil || entry.GetMetadata() == nil {
+		return nil, false
+	}
+	value, ok := EntryMetadata(entry)[key]
+	return value, ok
+}
+
+// GetEntryMetadataString returns a metadata value of an entry as a string ("" when missing).
+func GetEntryMetadataString(entry it.IFileEntry, key string) string {
+	if value, ok := GetEntryMetadataValue(entry, key); ok && value != nil {
+		if str, isStr := value.(string); isStr {
+			return str
+		}
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return ""
+}
+
+// SetEntryMetadataValue sets a single metadata value of an entry.
+func SetEntryMetadataValue(entry it.IFileEntry, key string, value any) {
+	if entry == nil {
+		return
+	}
+	EntryMetadata(entry)[key] = value
+}
+
+// ApplyEntryAnnotations stores the annotations in the entry metadata and maps the recognized keys
+// to the entry fields: "mode" sets the permissions and "checksum" the expected checksum.
+func ApplyEntryAnnotations(entry it.IFileEntry, annotations map[string]any) {
+	if entry == nil || len(annotations) == 0 {
+		return
+	}
+	for key, value := range annotations {
+		SetEntryMetadataValue(entry, key, value)
+	}
+	if mode := GetEntryMetadataString(entry, "mode"); mode != "" {
+		entry.SetPermissions(mode)
+	}
+	if checksum := GetEntryMetadataString(entry, "checksum"); checksum != "" {
+		entry.SetChecksum(checksum)
+	}
+}
+
+// HasExplicitPermissions reports if the entry permissions were set, instead of the default ones.
+func HasExplicitPermissions(entry it.IFileEntry) bool {
+	if fe, ok := entry.(*FileEntry); ok {
+		return fe.Permissions != ""
+	}
+	_, annotated := GetEntryMetadataValue(entry, "mode")
+	return annotated
+}
+
+// EntryChecksum returns the expected checksum of the entry, or "" when none was set.
+func EntryChecksum(entry it.IFileEntry) string {
+	if fe, ok := entry.(*FileEntry); ok {
+		return fe.Checksum
+	}
+	return GetEntryMetadataString(entry, "checksum")
+}
+
+// EntryComments returns the comment of the entry, or "" when it has none.
+func EntryComments(entry it.IFileEntry) string {
+	if fe, ok := entry.(*FileEntry); ok {
+		return fe.Comments
+	}
+	if comments := entry.GetComments(); comments != "No comments" {
+		return comments
+	}
+	return ""
+}
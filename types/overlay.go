@@ -0,0 +1,181 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// TreeOverlayOperation is a single change of an overlay file.
+type TreeOverlayOperation struct {
+	Kind    string `json:"kind" yaml:"kind" xml:"kind" toml:"kind"`             // "add", "remove", "rename" ou "annotate"
+	Path    string `json:"path" yaml:"path" xml:"path" toml:"path"`             // Caminho da entrada afetada
+	Target  string `json:"target" yaml:"target" xml:"target" toml:"target"`     // Novo caminho (rename)
+	Type    string `json:"type" yaml:"type" xml:"type" toml:"type"`             // Tipo da nova entrada (add)
+	Comment string `json:"comment" yaml:"comment" xml:"comment" toml:"comment"` // Comentário da entrada (add, annotate)
+	Line    int    `json:"line" yaml:"line" xml:"line" toml:"line"`             // Linha do arquivo de overlay
+}
+
+// TreeOverlay is a patch applied on top of a base tree, kustomize style.
+//
+// Each line of an overlay file is one operation, with paths relative to the tree:
+//
+//	# team.overlay
+//	+ services/payments/api.go   # adds an entry (a trailing "/" adds a directory)
+//	- legacy/                    # removes an entry and its subtree
+//	~ docs -> documentation      # renames or moves an entry
+//	= cmd/main.go # Entry point  # replaces the annotations of an entry
+//
+// Blank lines and lines starting with "#" are ignored.
+type TreeOverlay struct {
+	Source     string                 `json:"source" yaml:"source" xml:"source" toml:"source"`
+	Operations []TreeOverlayOperation `json:"operations" yaml:"operations" xml:"operations" toml:"operations"`
+}
+
+// LoadTreeOverlay reads and parses an overlay file.
+func LoadTreeOverlay(overlayPath string) (*TreeOverlay, error) {
+	lines, err := readTreeLines(overlayPath)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read overlay file: %s", err))
+		return nil, fmt.Errorf("failed to read overlay file '%s': %s", overlayPath, err)
+	}
+	overlay := &TreeOverlay{Source: overlayPath, Operations: make([]TreeOverlayOperation, 0)}
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if len(text) < 2 || text[1] != ' ' {
+			gl.Log("error", fmt.Sprintf("Invalid overlay operation at %s: %s", line.Position(), text))
+			return nil, fmt.Errorf("invalid overlay operation at %s: '%s'", line.Position(), text)
+		}
+		body, comment := utl.ExtractComment(strings.TrimSpace(text[2:]))
+		operation := TreeOverlayOperation{Path: body, Comment: comment, Line: line.Number}
+		switch text[0] {
+		case '+':
+			operation.Kind = "add"
+			operation.Type = "file"
+			if strings.HasSuffix(body, "/") || strings.HasSuffix(body, "\\") {
+				operation.Type = "directory"
+			}
+		case '-':
+			operation.Kind = "remove"
+		case '~':
+			source, target, found := strings.Cut(body, "->")
+			if !found || strings.TrimSpace(target) == "" {
+				gl.Log("error", fmt.Sprintf("Invalid rename at %s: %s", line.Position(), text))
+				return nil, fmt.Errorf("invalid rename at %s, expected '~ old -> new'", line.Position())
+			}
+			operation.Kind = "rename"
+			operation.Path = strings.TrimSpace(source)
+			operation.Target = strings.TrimSpace(target)
+		case '=':
+			operation.Kind = "annotate"
+		default:
+			gl.Log("error", fmt.Sprintf("Unknown overlay operation at %s: %s", line.Position(), text))
+			return nil, fmt.Errorf("unknown overlay operation '%c' at %s", text[0], line.Position())
+		}
+		if NormalizeEntryPath(operation.Path) == "" {
+			return nil, fmt.Errorf("empty path in overlay operation at %s", line.Position())
+		}
+		overlay.Operations = append(overlay.Operations, operation)
+	}
+	return overlay, nil
+}
+
+// Apply applies the overlay operations, in order, to the tree.
+func (o *TreeOverlay) Apply(ft it.IFileTree) error {
+	for _, operation := range o.Operations {
+		position := fmt.Sprintf("%s:%d", filepath.Base(o.Source), operation.Line)
+		switch operation.Kind {
+		case "add":
+			comment, annotations := utl.ParseAnnotations(operation.Comment)
+			entry, err := ft.AddEntryByPath(resolveOverlayPath(ft, operation.Path, true), operation.Type, comment)
+			if err != nil {
+				return fmt.Errorf("failed to add entry at %s: %w", position, err)
+			}
+			ApplyEntryAnnotations(entry, annotations)
+		case "remove":
+			entry := ft.GetEntryByPath(resolveOverlayPath(ft, operation.Path, false))
+			if entry == nil {
+				gl.Log("error", fmt.Sprintf("Entry to remove not found at %s: %s", position, operation.Path))
+				return fmt.Errorf("entry to remove not found at %s: '%s'", position, operation.Path)
+			}
+			if err := ft.RemoveEntry(entry.GetID()); err != nil {
+				return fmt.Errorf("failed to remove entry at %s: %w", position, err)
+			}
+		case "rename":
+			sourcePath := resolveOverlayPath(ft, operation.Path, false)
+			entry := ft.GetEntryByPath(sourcePath)
+			if entry == nil {
+				gl.Log("error", fmt.Sprintf("Entry to rename not found at %s: %s", position, operation.Path))
+				return fmt.Errorf("entry to rename not found at %s: '%s'", position, operation.Path)
+			}
+			// Um nome simples renomeia no mesmo diretório, um caminho move a entrada
+			target := NormalizeEntryPath(operation.Target)
+			if !strings.Contains(target, "/") {
+				if parentPath := path.Dir(sourcePath); parentPath != "." {
+					target = parentPath + "/" + target
+				}
+			} else {
+				target = resolveOverlayPath(ft, target, true)
+			}
+			if err := ft.MoveEntry(entry.GetID(), target); err != nil {
+				return fmt.Errorf("failed to rename entry at %s: %w", position, err)
+			}
+		case "annotate":
+			entry := ft.GetEntryByPath(resolveOverlayPath(ft, operation.Path, false))
+			if entry == nil {
+				gl.Log("error", fmt.Sprintf("Entry to annotate not found at %s: %s", position, operation.Path))
+				return fmt.Errorf("entry to annotate not found at %s: '%s'", position, operation.Path)
+			}
+			comment, annotations := utl.ParseAnnotations(operation.Comment)
+			if fe, ok := entry.(*FileEntry); ok {
+				fe.Comments = comment
+			} else if comment != "" {
+				entry.SetComments(comment)
+			}
+			ApplyEntryAnnotations(entry, annotations)
+		}
+	}
+	return nil
+}
+
+// ApplyTreeOverlay loads an overlay file and applies it to the tree.
+func ApplyTreeOverlay(ft it.IFileTree, overlayPath string) error {
+	overlay, err := LoadTreeOverlay(overlayPath)
+	if err != nil {
+		return err
+	}
+	if err := overlay.Apply(ft); err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to apply overlay '%s': %s", overlayPath, err))
+		return err
+	}
+	return nil
+}
+
+// resolveOverlayPath accepts paths relative to the tree or to its single root directory.
+func resolveOverlayPath(ft it.IFileTree, overlayPath string, creating bool) string {
+	overlayPath = NormalizeEntryPath(overlayPath)
+	if ft.GetEntryByPath(overlayPath) != nil {
+		return overlayPath
+	}
+	root := SingleRootEntry(ft)
+	if root == nil {
+		return overlayPath
+	}
+	rootPath := root.GetPath()
+	if strings.HasPrefix(overlayPath, rootPath+"/") {
+		return overlayPath
+	}
+	// Novas entradas ficam sempre dentro do diretório raiz único
+	if creating || ft.GetEntryByPath(rootPath+"/"+overlayPath) != nil {
+		return rootPath + "/" + overlayPath
+	}
+	return overlayPath
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+)
+
+// PlaceholderMarker opens the content of the generated placeholders that are not empty (e.g. READMEs).
+const PlaceholderMarker = "<!-- cleandgo:placeholder -->"
+
+// DefaultPlaceholderNames are the empty files recognized as placeholders of empty directories.
+var DefaultPlaceholderNames = []string{".gitkeep", ".keep"}
+
+// PlaceholderContent returns the content of the placeholder of a directory: Markdown placeholders are READMEs
+// generated from the directory comment, any other name is an empty file.
+func PlaceholderContent(name string, entry it.IFileEntry) []byte {
+	if !strings.EqualFold(filepath.Ext(name), ".md") {
+		return []byte{}
+	}
+	content := fmt.Sprintf("%s\n# %s\n", PlaceholderMarker, entry.GetName())
+	if comments := EntryComments(entry); comments != "" {
+		content += "\n" + comments + "\n"
+	}
+	return []byte(content)
+}
+
+// IsPlaceholderFile reports if a file is a placeholder: an empty file with one of the given (or default)
+// names, or a file generated with the placeholder marker.
+func IsPlaceholderFile(fsys it.IFileSystem, filePath string, names []string) bool {
+	info, err := fsys.Lstat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if info.Size() == 0 {
+		base := filepath.Base(filePath)
+		for _, name := range append(append([]string{}, DefaultPlaceholderNames...), names...) {
+			if base == name {
+				return true
+			}
+		}
+		return false
+	}
+	if info.Size() > 64*1024 {
+		return false
+	}
+	data, err := fsys.ReadFile(filePath)
+	return err == nil && bytes.HasPrefix(data, []byte(PlaceholderMarker))
+}
+
+// EnsureTreePlaceholders drops the placeholder file in the directories left empty, so version control keeps
+// them, and removes the placeholders it created in the directories that got other contents. Files listed in
+// the tree and files that existed before the composition are never removed.
+func (tc *TreeComposer) EnsureTreePlaceholders() error {
+	name := tc.Options.Placeholder
+	if name == "" {
+		return nil
+	}
+	fsys := tc.Options.FileSystem()
+	listed := make(map[string]bool, len(tc.FileTree.GetEntries()))
+	for _, entry := range tc.FileTree.GetEntries() {
+		if targetPath, err := tc.TargetPath(entry); err == nil {
+			listed[targetPath] = true
+		}
+	}
+	for _, entry := range tc.composedEntries() {
+		if entry.GetType() != "directory" {
+			continue
+		}
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return err
+		}
+		contents, err := fsys.ReadDir(targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to read directory '%s': %w", targetPath, err)
+		}
+		placeholders, others := make([]string, 0), 0
+		for _, content := range contents {
+			contentPath := filepath.Join(targetPath, content.Name())
+			if !listed[contentPath] && IsPlaceholderFile(fsys, contentPath, []string{name}) {
+				placeholders = append(placeholders, contentPath)
+			} else {
+				others++
+			}
+		}
+		if others > 0 {
+			for _, placeholder := range placeholders {
+				if !tc.placeholders[placeholder] {
+					continue // Criado pelo usuário ou por outra composição
+				}
+				if err := fsys.Remove(placeholder); err != nil {
+					return fmt.Errorf("failed to remove placeholder '%s': %w", placeholder, err)
+				}
+				delete(tc.placeholders, placeholder)
+			}
+			continue
+		}
+		if len(placeholders) == 0 {
+			placeholder := filepath.Join(targetPath, name)
+			if err := fsys.WriteFile(placeholder, PlaceholderContent(name, entry), 0644); err != nil {
+				return fmt.Errorf("failed to create placeholder '%s': %w", placeholder, err)
+			}
+			if tc.placeholders == nil {
+				tc.placeholders = make(map[string]bool)
+			}
+			tc.placeholders[placeholder] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+func TestEnsureTreePlaceholders(t *testing.T) {
+	const target = "/work/app"
+	fsys := utl.NewMemFileSystem()
+	// Diretório do usuário com um .gitkeep próprio e outro arquivo
+	if err := fsys.MkdirAll(filepath.Join(target, "old"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"old/.gitkeep", "old/y.txt"} {
+		if err := fsys.WriteFile(filepath.Join(target, name), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ft := parseTestTree(t, "keep/\n├── .gitkeep\n└── x.txt\nempty/\nold/")
+	ft.ComposerTargetPath = target
+	tc := &TreeComposer{FileTree: ft, Options: &TreeComposerOptions{FS: fsys, Placeholder: ".gitkeep", NoJournal: true}}
+	if err := tc.MakeTree(); err != nil {
+		t.Fatalf("MakeTree() error = %v", err)
+	}
+	for _, name := range []string{"keep/.gitkeep", "keep/x.txt", "empty/.gitkeep", "old/.gitkeep", "old/y.txt"} {
+		if !utl.CheckFileExistsIn(fsys, filepath.Join(target, name)) {
+			t.Errorf("%s is missing", name)
+		}
+	}
+
+	// Só o marcador criado pela composição sai quando o diretório ganha outro conteúdo
+	if err := fsys.WriteFile(filepath.Join(target, "empty", "z.txt"), []byte("z"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.EnsureTreePlaceholders(); err != nil {
+		t.Fatalf("EnsureTreePlaceholders() error = %v", err)
+	}
+	if utl.CheckFileExistsIn(fsys, filepath.Join(target, "empty", ".gitkeep")) {
+		t.Errorf("empty/.gitkeep was not removed")
+	}
+	for _, name := range []string{"keep/.gitkeep", "old/.gitkeep"} {
+		if !utl.CheckFileExistsIn(fsys, filepath.Join(target, name)) {
+			t.Errorf("%s was removed", name)
+		}
+	}
+}
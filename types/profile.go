@@ -0,0 +1,30 @@
+package types
+
+import (
+	"fmt"
+	"os"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// TreeProfile is a reusable set of features (and optional variables) applied when parsing a tree file.
+type TreeProfile struct {
+	Features []string       `json:"features" yaml:"features" xml:"features" toml:"features"`
+	Values   map[string]any `json:"values" yaml:"values" xml:"-" toml:"values"`
+}
+
+// LoadTreeProfile reads a profile file in YAML (or JSON) format.
+func LoadTreeProfile(profilePath string) (*TreeProfile, error) {
+	data, err := os.ReadFile(profilePath)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read profile file: %s", err))
+		return nil, fmt.Errorf("failed to read profile file '%s': %s", profilePath, err)
+	}
+	profile := &TreeProfile{}
+	if err := yaml.Unmarshal(data, profile); err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to parse profile file: %s", err))
+		return nil, fmt.Errorf("failed to parse profile file '%s': %s", profilePath, err)
+	}
+	return profile, nil
+}
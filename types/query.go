@@ -0,0 +1,356 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// queryTermRegex splits a query term in its key, operator and value (`key`, `!key` and `key<op>value`).
+var queryTermRegex = regexp.MustCompile(`^(!?)([A-Za-z_][\w.-]*)(?:(!=|>=|<=|=|~|>|<)(.*))?$`)
+
+// querySizeRegex matches a size with an optional binary unit (e.g. "512", "10k", "1.5MiB").
+var querySizeRegex = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*([kmgt]?)(?:i?b)?$`)
+
+// TreeQueryCondition is a condition of a query on a property of the entries.
+type TreeQueryCondition struct {
+	Key   string `json:"key" yaml:"key" xml:"key" toml:"key"`                                                 // Propriedade ou chave de metadado
+	Op    string `json:"op,omitempty" yaml:"op,omitempty" xml:"op,omitempty" toml:"op,omitempty"`             // "=", "!=", "~", ">", ">=", "<", "<=", "" (tem a chave) ou "!" (não tem)
+	Value string `json:"value,omitempty" yaml:"value,omitempty" xml:"value,omitempty" toml:"value,omitempty"` // Valor comparado
+}
+
+// TreeQuery selects the entries of a tree matching all its conditions. A query is written as space separated
+// `key<op>value` terms, e.g. `type=file depth>2 ext=.java tag=api`:
+//
+//   - path and name match a glob ("**" crosses directories); the path is tried from the tree root and from
+//     inside its single root directory
+//   - type is file, directory (or dir) or symlink
+//   - depth counts the path components (the top-level entries have depth 1), and size takes the units k, m,
+//     g and t (binary); both accept a `min..max` range after "="
+//   - ext is the extension, with or without the dot, in any case
+//   - comment matches the text contained in the comment, in any case
+//   - tag matches one of the `@tags`
+//   - any other key is a metadata key (annotations such as `owner`, `mode` or `from`, and custom `@key=value`),
+//     matched by glob, or by number with the comparisons; a bare `key` requires it and `!key` excludes it
+//
+// "=" accepts comma separated alternatives, "!=" negates it, "~" matches the contained text in any case and
+// values with spaces are quoted.
+type TreeQuery struct {
+	Text       string               `json:"text" yaml:"text" xml:"text" toml:"text"`                         // Texto da consulta
+	Conditions []TreeQueryCondition `json:"conditions" yaml:"conditions" xml:"conditions" toml:"conditions"` // Condições, todas exigidas
+}
+
+// ParseTreeQuery parses the text of a query. An empty query matches every entry.
+func ParseTreeQuery(text string) (*TreeQuery, error) {
+	terms, err := splitQueryTerms(text)
+	if err != nil {
+		return nil, err
+	}
+	query := &TreeQuery{Text: text, Conditions: make([]TreeQueryCondition, 0, len(terms))}
+	for _, term := range terms {
+		match := queryTermRegex.FindStringSubmatch(term)
+		if match == nil {
+			return nil, fmt.Errorf("invalid query term '%s', expected key<op>value", term)
+		}
+		condition := TreeQueryCondition{Key: strings.ToLower(match[2]), Op: match[3], Value: match[4]}
+		switch {
+		case match[1] != "" && condition.Op != "":
+			return nil, fmt.Errorf("invalid query term '%s', use != to negate a value", term)
+		case match[1] != "":
+			condition.Op = "!"
+		}
+		if condition.Key == "tags" {
+			condition.Key = "tag"
+		}
+		if err := checkQueryCondition(condition); err != nil {
+			return nil, fmt.Errorf("invalid query term '%s': %w", term, err)
+		}
+		query.Conditions = append(query.Conditions, condition)
+	}
+	return query, nil
+}
+
+// splitQueryTerms splits a query by the spaces outside quotes, removing the quotes.
+func splitQueryTerms(text string) ([]string, error) {
+	terms := make([]string, 0)
+	var term strings.Builder
+	var quote rune
+	started := false
+	for _, r := range text {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			term.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, started = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if started {
+				terms = append(terms, term.String())
+				term.Reset()
+				started = false
+			}
+		default:
+			term.WriteRune(r)
+			started = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in query '%s'", text)
+	}
+	if started {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// checkQueryCondition validates the operator and the value of a condition on a built-in property.
+func checkQueryCondition(condition TreeQueryCondition) error {
+	switch condition.Key {
+	case "depth", "size":
+		if condition.Op == "" || condition.Op == "!" || condition.Op == "~" {
+			return fmt.Errorf("%s takes =, !=, >, >=, < or <=", condition.Key)
+		}
+		low, high, _ := strings.Cut(condition.Value, "..")
+		for _, bound := range []string{low, high} {
+			if _, err := parseQueryNumber(condition.Key, bound); bound != "" && err != nil {
+				return err
+			}
+		}
+		if low == "" && high == "" {
+			return fmt.Errorf("%s requires a number", condition.Key)
+		}
+	case "path", "name", "type", "ext", "comment", "tag":
+		switch condition.Op {
+		case "", "!":
+			return fmt.Errorf("%s requires a value", condition.Key)
+		case ">", ">=", "<", "<=":
+			return fmt.Errorf("%s takes =, != or ~", condition.Key)
+		}
+		if condition.Key == "type" && condition.Op != "~" {
+			for _, kind := range strings.Split(condition.Value, ",") {
+				if normalizeQueryType(kind) == "" {
+					return fmt.Errorf("unknown type '%s', expected file, directory or symlink", kind)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseQueryNumber parses a depth or a size (with its unit).
+func parseQueryNumber(key, value string) (float64, error) {
+	if key != "size" {
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	}
+	match := querySizeRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size '%s', expected a number with an optional k, m, g or t unit", value)
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	shift := strings.Index("kmgt", strings.ToLower(match[2])) + 1
+	if match[2] == "" {
+		shift = 0
+	}
+	return number * float64(int64(1)<<(10*shift)), nil
+}
+
+// normalizeQueryType returns the entry type named in a query, or "" when unknown.
+func normalizeQueryType(kind string) string {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "file", "f":
+		return "file"
+	case "directory", "dir", "d":
+		return "directory"
+	case "symlink", "link", "l":
+		return "symlink"
+	}
+	return ""
+}
+
+// Matches reports if the entry holds all the conditions of the query.
+func (q *TreeQuery) Matches(ft it.IFileTree, entry it.IFileEntry) bool {
+	for _, condition := range q.Conditions {
+		if !condition.Matches(ft, entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports if the entry holds the condition.
+func (c TreeQueryCondition) Matches(ft it.IFileTree, entry it.IFileEntry) bool {
+	if c.Op == "!=" {
+		negated := c
+		negated.Op = "="
+		return !negated.Matches(ft, entry)
+	}
+	switch c.Key {
+	case "path":
+		return c.matchText(entry.GetPath(), RootRelativePath(ft, entry))
+	case "name":
+		return c.matchText(entry.GetName())
+	case "type":
+		if c.Op == "~" {
+			return c.matchText(entry.GetType())
+		}
+		for _, kind := range strings.Split(c.Value, ",") {
+			if normalizeQueryType(kind) == entry.GetType() {
+				return true
+			}
+		}
+		return false
+	case "ext":
+		if entry.GetType() != "file" {
+			return false
+		}
+		ext := strings.ToLower(path.Ext(entry.GetName()))
+		if c.Op == "~" {
+			return c.matchText(ext)
+		}
+		for _, want := range strings.Split(strings.ToLower(c.Value), ",") {
+			if want = strings.TrimSpace(want); ext != "" && (want == ext || "."+want == ext) {
+				return true
+			}
+		}
+		return false
+	case "comment":
+		return strings.Contains(strings.ToLower(EntryComments(entry)), strings.ToLower(c.Value))
+	case "tag":
+		value, _ := GetEntryMetadataValue(entry, "tags")
+		return c.matchText(queryMetadataValues(value)...)
+	case "depth":
+		return c.matchNumber(float64(pathDepth(entry.GetPath())))
+	case "size":
+		return entry.GetType() == "file" && c.matchNumber(float64(entry.GetSize()))
+	}
+	value, ok := GetEntryMetadataValue(entry, c.Key)
+	switch c.Op {
+	case "":
+		return ok
+	case "!":
+		return !ok
+	case ">", ">=", "<", "<=":
+		number, err := strconv.ParseFloat(GetEntryMetadataString(entry, c.Key), 64)
+		return ok && err == nil && c.matchNumber(number)
+	}
+	return ok && c.matchText(queryMetadataValues(value)...)
+}
+
+// matchText reports if one of the values matches the glob alternatives ("=") or contains the text ("~").
+func (c TreeQueryCondition) matchText(values ...string) bool {
+	for _, value := range values {
+		if c.Op == "~" {
+			if strings.Contains(strings.ToLower(value), strings.ToLower(c.Value)) {
+				return true
+			}
+			continue
+		}
+		for _, pattern := range strings.Split(c.Value, ",") {
+			if value != "" && (pattern == value || utl.MatchGlob(pattern, value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchNumber compares a number with the value of the condition (a `min..max` range with "=").
+func (c TreeQueryCondition) matchNumber(number float64) bool {
+	if low, high, isRange := strings.Cut(c.Value, ".."); isRange && c.Op == "=" {
+		from, fromErr := parseQueryNumber(c.Key, low)
+		to, toErr := parseQueryNumber(c.Key, high)
+		return (low == "" || (fromErr == nil && number >= from)) && (high == "" || (toErr == nil && number <= to))
+	}
+	want, err := parseQueryNumber(c.Key, c.Value)
+	if err != nil {
+		return false
+	}
+	switch c.Op {
+	case "=":
+		return number == want
+	case ">":
+		return number > want
+	case ">=":
+		return number >= want
+	case "<":
+		return number < want
+	case "<=":
+		return number <= want
+	}
+	return false
+}
+
+// queryMetadataValues returns the texts of a metadata value (each item of a list).
+func queryMetadataValues(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return items
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+// TreeQueryMatch is an entry matched by a query, as reported by `cleandgo query --format json`.
+type TreeQueryMatch struct {
+	Path     string         `json:"path" yaml:"path" xml:"path" toml:"path"`                                           // Caminho da entrada
+	Type     string         `json:"type" yaml:"type" xml:"type" toml:"type"`                                           // Tipo da entrada
+	Depth    int            `json:"depth" yaml:"depth" xml:"depth" toml:"depth"`                                       // Número de componentes do caminho
+	Size     int64          `json:"size,omitempty" yaml:"size,omitempty" xml:"size,omitempty" toml:"size,omitempty"`   // Tamanho (arquivos)
+	Comment  string         `json:"comment,omitempty" yaml:"comment,omitempty" xml:"comment,omitempty" toml:"comment"` // Comentário da entrada
+	Metadata map[string]any `json:"metadata,omitempty" yaml:"metadata,omitempty" xml:"-" toml:"metadata,omitempty"`    // Anotações e outros metadados
+}
+
+// NewTreeQueryMatch describes an entry matched by a query.
+func NewTreeQueryMatch(entry it.IFileEntry) TreeQueryMatch {
+	match := TreeQueryMatch{
+		Path:    entry.GetPath(),
+		Type:    entry.GetType(),
+		Depth:   pathDepth(entry.GetPath()),
+		Comment: EntryComments(entry),
+	}
+	if entry.GetType() == "file" {
+		match.Size = entry.GetSize()
+	}
+	if metadata := EntryMetadata(entry); len(metadata) > 0 {
+		match.Metadata = metadata
+	}
+	return match
+}
+
+// QueryTree returns the entries of the tree matching the query, in the order of the tree.
+func QueryTree(ft it.IFileTree, query *TreeQuery) []it.IFileEntry {
+	matches := make([]it.IFileEntry, 0)
+	var walk func(parentID uuid.UUID)
+	walk = func(parentID uuid.UUID) {
+		for _, entry := range ft.GetChildren(parentID) {
+			if query.Matches(ft, entry) {
+				matches = append(matches, entry)
+			}
+			if entry.GetType() == "directory" {
+				walk(entry.GetID())
+			}
+		}
+	}
+	walk(uuid.Nil)
+	return matches
+}
@@ -0,0 +1,178 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// TreeRenderOptions holds the optional settings used while rendering a tree view.
+type TreeRenderOptions struct {
+	// Comments renders the comments and the recognized annotations (mode, owner, tags...) of the entries.
+	Comments bool
+	// Sizes appends the size of the files to their comments.
+	Sizes bool
+	// Modes appends the permissions of the entries to their comments, as `@mode=` annotations.
+	Modes bool
+	// MaxDepth limits the depth of the rendered entries (0 renders the whole tree).
+	MaxDepth int
+	// IDs renders the `@id=` annotation of the entries first in their comments (see TreeEdit).
+	IDs bool
+	// Escape writes the names and link targets with a backslash before the characters read specially by the
+	// edit views, so they are read back literally (see TreeEdit).
+	Escape bool
+	// Paths limits the drawing to the entries with these paths and their ancestors (e.g. the matches of a
+	// query). Empty renders every entry.
+	Paths []string
+}
+
+// RenderTreeView draws a tree the way it is written in tree files (directories end with "/" and symlinks are
+// drawn as `name -> target`), so the drawing can be parsed back. Entries keep the order of the tree.
+func RenderTreeView(ft it.IFileTree, options *TreeRenderOptions) string {
+	if options == nil {
+		options = &TreeRenderOptions{}
+	}
+	var kept map[string]bool
+	if len(options.Paths) > 0 {
+		kept = make(map[string]bool, len(options.Paths))
+		for _, entryPath := range options.Paths {
+			for p := NormalizeEntryPath(entryPath); p != "." && p != "" && !kept[p]; p = path.Dir(p) {
+				kept[p] = true
+			}
+		}
+	}
+	var sb strings.Builder
+	renderTreeLevel(&sb, ft, ft.GetChildren(uuid.Nil), "", 0, options, kept)
+	return sb.String()
+}
+
+// renderTreeLevel draws the entries of a level, prefixed with the drawing of their ancestors. A kept set
+// limits the drawing to its paths.
+func renderTreeLevel(sb *strings.Builder, ft it.IFileTree, entries []it.IFileEntry, prefix string, depth int, options *TreeRenderOptions, kept map[string]bool) {
+	if options.MaxDepth > 0 && depth >= options.MaxDepth {
+		return
+	}
+	if kept != nil {
+		visible := make([]it.IFileEntry, 0, len(entries))
+		for _, entry := range entries {
+			if kept[entry.GetPath()] {
+				visible = append(visible, entry)
+			}
+		}
+		entries = visible
+	}
+	for i, entry := range entries {
+		last := i == len(entries)-1
+		branch, continuation := "├── ", "│   "
+		if last {
+			branch, continuation = "└── ", "    "
+		}
+		if depth == 0 {
+			branch, continuation = "", ""
+		}
+		sb.WriteString(prefix + branch + renderEntryLine(entry, options) + "\n")
+		if entry.GetType() == "directory" {
+			renderTreeLevel(sb, ft, ft.GetChildren(entry.GetID()), prefix+continuation, depth+1, options, kept)
+		}
+	}
+}
+
+// renderEntryLine draws the name of an entry, with its comment when enabled.
+func renderEntryLine(entry it.IFileEntry, options *TreeRenderOptions) string {
+	name, linkTarget := entry.GetName(), GetEntryMetadataString(entry, "linkTarget")
+	if options.Escape {
+		name, linkTarget = escapeEditViewText(name), escapeEditViewText(linkTarget)
+	}
+	line := name
+	switch entry.GetType() {
+	case "directory":
+		line += "/"
+	case "symlink":
+		line += " -> " + linkTarget
+	}
+
+	comment := make([]string, 0)
+	if id := GetEntryMetadataString(entry, "id"); options.IDs && id != "" {
+		comment = append(comment, "@id="+id)
+	}
+	if text := strings.TrimSpace(EntryComments(entry)); options.Comments && text != "" {
+		comment = append(comment, text)
+	}
+	if options.Sizes && entry.GetType() == "file" {
+		comment = append(comment, FormatSize(entry.GetSize()))
+	}
+	if options.Modes && entry.GetPermissions() != "" {
+		comment = append(comment, "@mode="+entry.GetPermissions())
+	}
+	if options.Comments {
+		comment = append(comment, renderEntryAnnotations(entry, options.Modes)...)
+	}
+	if len(comment) == 0 {
+		return line
+	}
+	return line + "  # " + strings.Join(comment, " ")
+}
+
+// renderEntryAnnotations returns the recognized annotations of an entry in the `@key=value` form, sorted by key,
+// with the `run:` command last (it takes the rest of the comment).
+func renderEntryAnnotations(entry it.IFileEntry, skipMode bool) []string {
+	keys := make([]string, 0, len(utl.AnnotationKeys))
+	for key := range utl.AnnotationKeys {
+		if key == "run" || (key == "mode" && skipMode) {
+			continue
+		}
+		if _, ok := GetEntryMetadataValue(entry, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	annotations := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		value, _ := GetEntryMetadataValue(entry, key)
+		annotations = append(annotations, "@"+key+"="+formatAnnotationValue(value))
+	}
+	if command := GetEntryMetadataString(entry, "run"); command != "" {
+		annotations = append(annotations, "run: "+command)
+	}
+	return annotations
+}
+
+// formatAnnotationValue formats an annotation value as parsed back by ParseAnnotations.
+func formatAnnotationValue(value any) string {
+	var text string
+	switch v := value.(type) {
+	case []string:
+		text = strings.Join(v, ",")
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		text = strings.Join(items, ",")
+	default:
+		text = fmt.Sprint(v)
+	}
+	if strings.ContainsAny(text, " \t") {
+		return `"` + text + `"`
+	}
+	return text
+}
+
+// FormatSize formats a size in bytes with binary units (e.g. "12 B", "3.4 KiB").
+func FormatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, unit := float64(size), 0
+	for value >= 1024 && unit < 5 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTPE"[unit-1])
+}
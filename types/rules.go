@@ -0,0 +1,199 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// ruleRegex matches `@rule <file|dir> <glob> [!glob ...] [having <glob>] => <name>`.
+var ruleRegex = regexp.MustCompile(`^@rule\s+(file|dir)\s+(.+?)\s*=>\s*(\S.*)$`)
+
+// TreeRule generates companion entries for the entries matching its globs.
+// File rules add a sibling of each matched file, and dir rules add a child to each matched directory:
+//
+//	@rule file internal/**/*.go !**/*_test.go => {{.Stem}}_test.go
+//	@rule dir cmd/* => main.go
+//	@rule dir src/main/java/** having *.java => package-info.java
+//
+// The target name is a template with the Name, Stem, Ext, Dir and Path of the matched entry.
+type TreeRule struct {
+	Kind     string   `json:"kind" yaml:"kind" xml:"kind" toml:"kind"`                 // "file" ou "dir"
+	Patterns []string `json:"patterns" yaml:"patterns" xml:"patterns" toml:"patterns"` // Globs das entradas, "!" exclui
+	Having   string   `json:"having" yaml:"having" xml:"having" toml:"having"`         // Glob de um filho exigido (regras dir)
+	Target   string   `json:"target" yaml:"target" xml:"target" toml:"target"`         // Template do nome da entrada gerada
+	Text     string   `json:"text" yaml:"text" xml:"text" toml:"text"`                 // Texto original da regra
+}
+
+// TreeRuleData is the data available to the target template of a rule.
+type TreeRuleData struct {
+	Name string // Nome da entrada casada
+	Stem string // Nome sem a extensão
+	Ext  string // Extensão, com o ponto
+	Dir  string // Caminho do diretório pai
+	Path string // Caminho da entrada casada
+}
+
+// ParseTreeRule parses a rule, with or without the leading "@rule" keyword.
+func ParseTreeRule(text string) (*TreeRule, error) {
+	text = stripDirectiveComment(strings.TrimSpace(text))
+	if !strings.HasPrefix(text, "@rule") {
+		text = "@rule " + text
+	}
+	matches := ruleRegex.FindStringSubmatch(text)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid rule '%s', expected '@rule <file|dir> <glob> => <name>'", text)
+	}
+	rule := &TreeRule{Kind: matches[1], Target: strings.TrimSpace(matches[3]), Text: text}
+	args := splitDirectiveArgs(matches[2])
+	for i := 0; i < len(args); i++ {
+		if args[i] == "having" && i+1 < len(args) {
+			rule.Having = args[i+1]
+			i++
+			continue
+		}
+		rule.Patterns = append(rule.Patterns, args[i])
+	}
+	if len(rule.Patterns) == 0 {
+		return nil, fmt.Errorf("rule '%s' has no glob", text)
+	}
+	return rule, nil
+}
+
+// LoadTreeRules reads a rules file, one rule per line (blank lines and "#" comments are ignored).
+func LoadTreeRules(rulesPath string) ([]string, error) {
+	lines, err := readTreeLines(rulesPath)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read rules file: %s", err))
+		return nil, fmt.Errorf("failed to read rules file '%s': %s", rulesPath, err)
+	}
+	rules := make([]string, 0)
+	for _, line := range lines {
+		text := strings.TrimSpace(line.Text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if _, err := ParseTreeRule(text); err != nil {
+			return nil, fmt.Errorf("%s: %w", line.Position(), err)
+		}
+		rules = append(rules, text)
+	}
+	return rules, nil
+}
+
+// Matches reports if the entry is matched by the rule.
+func (r *TreeRule) Matches(ft it.IFileTree, entry it.IFileEntry) bool {
+	if (r.Kind == "file" && entry.GetType() != "file") || (r.Kind == "dir" && entry.GetType() != "directory") {
+		return false
+	}
+	candidates := []string{entry.GetPath(), RootRelativePath(ft, entry)}
+	matched := false
+	for _, pattern := range r.Patterns {
+		exclude := strings.HasPrefix(pattern, "!")
+		for _, candidate := range candidates {
+			if candidate != "" && utl.MatchGlob(strings.TrimPrefix(pattern, "!"), candidate) {
+				if exclude {
+					return false
+				}
+				matched = true
+			}
+		}
+	}
+	if !matched {
+		return false
+	}
+	if r.Having != "" {
+		for _, child := range ft.GetChildren(entry.GetID()) {
+			if utl.MatchGlob(r.Having, child.GetName()) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ApplyTreeRules evaluates the rules over the entries of the tree and adds the generated entries.
+// The generated entries are not matched again, and carry the rule that created them in the metadata.
+func ApplyTreeRules(ft it.IFileTree, rules []string) (int, error) {
+	parsed := make([]*TreeRule, 0, len(rules))
+	for _, text := range rules {
+		rule, err := ParseTreeRule(text)
+		if err != nil {
+			gl.Log("error", fmt.Sprintf("Invalid tree rule: %s", err))
+			return 0, err
+		}
+		parsed = append(parsed, rule)
+	}
+
+	generated := 0
+	entries := append([]it.IFileEntry{}, ft.GetEntries()...) // Apenas as entradas anteriores às regras
+	for _, rule := range parsed {
+		for _, entry := range entries {
+			if !rule.Matches(ft, entry) {
+				continue
+			}
+			ext := path.Ext(entry.GetName())
+			parentPath := ""
+			if entry.GetParent() != nil {
+				parentPath = entry.GetParent().GetPath()
+			}
+			name, err := utl.RenderTemplate(rule.Text, rule.Target, TreeRuleData{
+				Name: entry.GetName(),
+				Stem: strings.TrimSuffix(entry.GetName(), ext),
+				Ext:  ext,
+				Dir:  parentPath,
+				Path: entry.GetPath(),
+			})
+			if err != nil {
+				return generated, fmt.Errorf("failed to render rule '%s' for '%s': %w", rule.Text, entry.GetPath(), err)
+			}
+
+			// Regras de arquivo geram irmãos, regras de diretório geram filhos
+			targetPath := path.Join(entry.GetPath(), name)
+			if rule.Kind == "file" {
+				targetPath = path.Join(parentPath, name)
+			}
+			if ft.GetEntryByPath(targetPath) != nil {
+				continue // Já existe na árvore
+			}
+			entryType := "file"
+			if strings.HasSuffix(name, "/") {
+				entryType = "directory"
+			}
+			created, err := ft.AddEntryByPath(targetPath, entryType, "")
+			if err != nil {
+				return generated, fmt.Errorf("failed to add entry for rule '%s': %w", rule.Text, err)
+			}
+			SetEntryMetadataValue(created, "rule", rule.Text)
+			SetEntryMetadataValue(created, "ruleMatch", entry.GetPath())
+			generated++
+		}
+	}
+	gl.Log("debug", fmt.Sprintf("Tree rules generated %d entries", generated))
+	return generated, nil
+}
+
+// extractTreeRules removes the `@rule` lines from the tree lines, returning them apart.
+func extractTreeRules(lines []treeLine) ([]treeLine, []string, error) {
+	remaining := make([]treeLine, 0, len(lines))
+	rules := make([]string, 0)
+	for _, line := range lines {
+		text := strings.TrimSpace(strings.TrimPrefix(line.Text, utl.TreeLinePrefix(line.Text)))
+		if !strings.HasPrefix(text, "@rule") {
+			remaining = append(remaining, line)
+			continue
+		}
+		if _, err := ParseTreeRule(text); err != nil {
+			gl.Log("error", fmt.Sprintf("Invalid rule at %s: %s", line.Position(), err))
+			return nil, nil, fmt.Errorf("%s: %w", line.Position(), err)
+		}
+		rules = append(rules, stripDirectiveComment(text))
+	}
+	return remaining, rules, nil
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// DirectoryScanOptions holds the optional settings used while scanning a directory into a tree.
+type DirectoryScanOptions struct {
+	// Ignore are globs of the paths (relative to the scanned directory) or names left out of the tree.
+	Ignore []string
+	// Placeholders are additional placeholder names, ignored like the default ones when they are placeholders.
+	Placeholders []string
+	// FS is the filesystem scanned (the OS filesystem when nil).
+	FS it.IFileSystem
+}
+
+// FileSystem returns the filesystem scanned.
+func (o *DirectoryScanOptions) FileSystem() it.IFileSystem {
+	if o.FS == nil {
+		o.FS = utl.NewOsFileSystem()
+	}
+	return o.FS
+}
+
+// ScanDirectoryTree builds a tree from the contents of a directory, with the paths relative to it (so the
+// scanned directory plays the part of the composer target). Placeholders and the state directory of cleandgo
+// are left out of the tree, symlinks keep their target in the "linkTarget" metadata, and the entries carry
+// their size, mode and modification time.
+func ScanDirectoryTree(dir string, options *DirectoryScanOptions) (it.IFileTree, error) {
+	if options == nil {
+		options = &DirectoryScanOptions{}
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	fsys := options.FileSystem()
+	if info, statErr := fsys.Stat(absDir); statErr != nil || !info.IsDir() {
+		gl.Log("error", fmt.Sprintf("Directory to scan not found: %s", dir))
+		return nil, fmt.Errorf("directory '%s' not found", dir)
+	}
+
+	ft := newEmptyFileTree("", absDir, false, nil, &FileTreeOptions{FS: fsys})
+	err = fsys.Walk(absDir, func(filePath string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if filePath == absDir {
+			return nil
+		}
+		rel, relErr := filepath.Rel(absDir, filePath)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == StateDirName || isScanIgnored(rel, options.Ignore) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		entryType := "file"
+		switch {
+		case info.IsDir():
+			entryType = "directory"
+		case info.Mode()&os.ModeSymlink != 0:
+			entryType = "symlink"
+		case IsPlaceholderFile(fsys, filePath, options.Placeholders):
+			return nil // Placeholders não fazem parte da árvore
+		}
+
+		entry, addErr := ft.AddEntryByPath(rel, entryType, "")
+		if addErr != nil {
+			return addErr
+		}
+		modifiedAt := info.ModTime()
+		entry.SetModifiedAt(&modifiedAt)
+		entry.SetPermissions(utl.FormatPermissions(info.Mode()))
+		if entryType == "file" {
+			entry.SetSize(info.Size())
+		}
+		if entryType == "symlink" {
+			target, linkErr := fsys.Readlink(filePath)
+			if linkErr != nil {
+				return linkErr
+			}
+			SetEntryMetadataValue(entry, "linkTarget", target)
+		}
+		return nil
+	})
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to scan directory '%s': %s", dir, err))
+		return nil, fmt.Errorf("failed to scan directory '%s': %w", dir, err)
+	}
+	return ft, nil
+}
+
+// isScanIgnored reports if a path (or its name) matches one of the ignore globs.
+func isScanIgnored(rel string, ignore []string) bool {
+	for _, pattern := range ignore {
+		if utl.MatchGlob(pattern, rel) || utl.MatchGlob(pattern, path.Base(rel)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// BuiltinSkeletons are the starter contents written for new files, keyed by extension (without the dot)
+// or by the full file name. They are rendered with the TemplateData of the entry.
+var BuiltinSkeletons = map[string]string{
+	"go": "package {{.Package}}\n",
+	"java": `{{if .JavaPackage}}package {{.JavaPackage}};
+
+{{end}}public class {{.Class}} {
+}
+`,
+	"sh": "#!/usr/bin/env bash\n",
+	"md": `---
+title: {{.Title}}
+{{- if .Comments}}
+description: {{.Comments}}
+{{- end}}
+---
+
+# {{.Title}}
+`,
+	"package-info.java": "{{if .JavaPackage}}package {{.JavaPackage}};\n{{end}}",
+	"module-info.java":  "{{if .JavaPackage}}module {{.JavaPackage}} {\n}\n{{end}}",
+}
+
+var identifierRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// TemplateData is the data available to the content skeletons and templates of an entry.
+type TemplateData struct {
+	Name        string         // Nome do arquivo
+	Stem        string         // Nome sem a extensão
+	Ext         string         // Extensão, com o ponto
+	Path        string         // Caminho da entrada na árvore
+	Dir         string         // Caminho do diretório pai
+	DirName     string         // Nome do diretório pai
+	Package     string         // Nome do pacote Go (diretório pai, ou "main" para main.go)
+	JavaPackage string         // Pacote Java derivado do caminho do diretório (ex: com.x.y)
+	Class       string         // Nome da classe Java
+	Title       string         // Título derivado do nome
+	Comments    string         // Comentário livre da entrada
+	Metadata    map[string]any // Metadados da entrada
+	Values      map[string]any // Variáveis de template da árvore
+}
+
+// NewTemplateData builds the template data of an entry.
+func NewTemplateData(entry it.IFileEntry, values map[string]any) TemplateData {
+	name := entry.GetName()
+	entryPath := entry.GetPath()
+	ext := path.Ext(name)
+	dir := path.Dir(entryPath)
+	if dir == "." {
+		dir = ""
+	}
+	dirName := path.Base(dir)
+	if dir == "" {
+		dirName = ""
+	}
+
+	comments := EntryComments(entry)
+	metadata := map[string]any(EntryMetadata(entry))
+	if values == nil {
+		values = make(map[string]any)
+	}
+
+	stem := strings.TrimSuffix(name, ext)
+	goPackage := strings.ToLower(identifierRegex.ReplaceAllString(dirName, "_"))
+	if stem == "main" || goPackage == "" {
+		goPackage = "main"
+	}
+
+	return TemplateData{
+		Name:        name,
+		Stem:        stem,
+		Ext:         ext,
+		Path:        entryPath,
+		Dir:         dir,
+		DirName:     dirName,
+		Package:     goPackage,
+		JavaPackage: javaPackageFromDir(dir),
+		Class:       identifierRegex.ReplaceAllString(stem, "_"),
+		Title:       utl.ToTitleCase(stem),
+		Comments:    comments,
+		Metadata:    metadata,
+		Values:      values,
+	}
+}
+
+// javaPackageFromDir derives the Java package from the directory path, using the segments after
+// the last "java" directory (as in src/main/java/com/x/y) when there is one.
+func javaPackageFromDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	segments := strings.Split(dir, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if segments[i] == "java" {
+			segments = segments[i+1:]
+			break
+		}
+	}
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment = identifierRegex.ReplaceAllString(segment, "_"); segment != "" {
+			parts = append(parts, segment)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// LoadSkeletonDir reads user skeletons from a directory. Each "<key>.tmpl" file overrides the skeleton
+// of that extension (e.g. "go.tmpl") or file name (e.g. "Makefile.tmpl").
+func LoadSkeletonDir(skeletonDir string) (map[string]string, error) {
+	files, err := os.ReadDir(skeletonDir)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to read skeleton directory: %s", err))
+		return nil, fmt.Errorf("failed to read skeleton directory '%s': %s", skeletonDir, err)
+	}
+	skeletons := make(map[string]string)
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".tmpl" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(skeletonDir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read skeleton '%s': %s", file.Name(), err)
+		}
+		skeletons[strings.TrimPrefix(strings.TrimSuffix(file.Name(), ".tmpl"), ".")] = string(data)
+	}
+	return skeletons, nil
+}
+
+// RenderSkeleton returns the starter content of an entry, preferring the user skeletons over the built-in
+// ones and the file name over the extension. It returns false when there is no skeleton for the entry.
+func RenderSkeleton(entry it.IFileEntry, userSkeletons map[string]string, values map[string]any) (string, bool, error) {
+	name := entry.GetName()
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	for _, skeletons := range []map[string]string{userSkeletons, BuiltinSkeletons} {
+		for _, key := range []string{name, ext} {
+			skeleton, ok := skeletons[key]
+			if !ok || key == "" {
+				continue
+			}
+			content, err := utl.RenderTemplate(key, skeleton, NewTemplateData(entry, values))
+			if err != nil {
+				return "", false, fmt.Errorf("failed to render skeleton for '%s': %w", entry.GetPath(), err)
+			}
+			return content, true, nil
+		}
+	}
+	return "", false, nil
+}
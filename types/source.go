@@ -0,0 +1,42 @@
+package types
+
+import (
+	"fmt"
+	"path/filepath"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+)
+
+// EntrySourcePath resolves the `from=<path>` annotation of an entry, returning an empty path when there is none.
+// Relative sources are resolved against the directory of the file that declared the entry: the included
+// fragment, when the entry came from an `@include`, or the tree file. The source must exist in the filesystem.
+func EntrySourcePath(fsys it.IFileSystem, ft *FileTree, entry it.IFileEntry) (string, error) {
+	source := GetEntryMetadataString(entry, "from")
+	if source == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(source) {
+		baseFile := GetEntryMetadataString(entry, "include")
+		if baseFile == "" {
+			baseFile = ft.TreeFileSource
+		}
+		baseDir := "."
+		if baseFile != "" {
+			baseDir = filepath.Dir(baseFile)
+		}
+		source = filepath.Join(baseDir, filepath.FromSlash(source))
+	}
+	info, err := fsys.Stat(source)
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Source of '%s' not found: %s", entry.GetPath(), source))
+		return "", fmt.Errorf("source '%s' of '%s' not found", source, entry.GetPath())
+	}
+	if entry.GetType() == "directory" && !info.IsDir() {
+		return "", fmt.Errorf("source '%s' of directory '%s' is not a directory", source, entry.GetPath())
+	}
+	if entry.GetType() != "directory" && info.IsDir() {
+		return "", fmt.Errorf("source '%s' of file '%s' is a directory", source, entry.GetPath())
+	}
+	return source, nil
+}
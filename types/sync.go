@@ -0,0 +1,229 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+// DefaultQuarantineDir is the quarantine directory, relative to the target, used when none is given.
+var DefaultQuarantineDir = path.Join(StateDirName, "quarantine")
+
+// TreeSyncOptions holds the optional settings used while syncing a target directory with a tree.
+type TreeSyncOptions struct {
+	// Prune removes the paths of the target that are not in the tree (the extras).
+	Prune bool
+	// QuarantineDir receives the pruned and the wrongly typed paths, under a directory named by the sync time,
+	// instead of deleting them. Relative directories are resolved against the target.
+	QuarantineDir string
+	// Ignore are globs of the paths (relative to the target) or names protected from the sync.
+	Ignore []string
+	// DryRun only reports the actions, changing nothing.
+	DryRun bool
+}
+
+// TreeSyncAction is a change made (or planned, in dry run) to sync a target directory with a tree.
+type TreeSyncAction struct {
+	Action string `json:"action" yaml:"action" xml:"action" toml:"action"` // "create", "retype", "relink", "chmod", "prune" ou "quarantine"
+	Path   string `json:"path" yaml:"path" xml:"path" toml:"path"`         // Caminho relativo ao destino
+	Detail string `json:"detail" yaml:"detail" xml:"detail" toml:"detail"` // Detalhes da ação (tipos, modos, destino da quarentena)
+}
+
+// TreeSyncReport is the outcome of syncing a target directory with a tree.
+type TreeSyncReport struct {
+	Actions []TreeSyncAction `json:"actions" yaml:"actions" xml:"actions" toml:"actions"` // Ações, na ordem em que foram feitas
+	Extras  []string         `json:"extras" yaml:"extras" xml:"extras" toml:"extras"`     // Caminhos fora da árvore mantidos (sem --prune)
+}
+
+// SyncTree makes the target directory match the tree: the missing entries are created, the entries of the
+// wrong type are replaced, symlinks are pointed to their targets and the explicit modes are applied. With
+// Prune, the extras (paths not in the tree, nor below a `from=` directory) are deleted or quarantined. The
+// ignored paths, the placeholders and the state directory are never touched. Hooks run only for the entries
+// created by the sync.
+func (tc *TreeComposer) SyncTree(options *TreeSyncOptions) (*TreeSyncReport, error) {
+	if options == nil {
+		options = &TreeSyncOptions{}
+	}
+	fsys := tc.Options.FileSystem()
+	target := tc.FileTree.ComposerTargetPath
+	report := &TreeSyncReport{Actions: make([]TreeSyncAction, 0), Extras: make([]string, 0)}
+
+	quarantine := ""
+	if options.QuarantineDir != "" {
+		quarantine = options.QuarantineDir
+		if !filepath.IsAbs(quarantine) {
+			quarantine = filepath.Join(target, filepath.FromSlash(quarantine))
+		}
+		quarantine = filepath.Join(quarantine, time.Now().Format("20060102-150405"))
+	}
+	ignore := append([]string{}, options.Ignore...)
+	if rel, err := filepath.Rel(target, quarantine); quarantine != "" && err == nil && !strings.HasPrefix(rel, "..") {
+		ignore = append(ignore, filepath.ToSlash(filepath.Dir(rel))) // A quarentena não é um extra
+	}
+
+	actual := newEmptyFileTree("", target, false, nil, nil)
+	if info, err := fsys.Stat(target); err == nil && info.IsDir() {
+		placeholders := make([]string, 0)
+		if tc.Options.Placeholder != "" {
+			placeholders = append(placeholders, tc.Options.Placeholder)
+		}
+		scanned, err := ScanDirectoryTree(target, &DirectoryScanOptions{Ignore: ignore, Placeholders: placeholders, FS: fsys})
+		if err != nil {
+			return nil, err
+		}
+		actual = scanned.GetFileTreeType().(*FileTree)
+	}
+
+	// Remove ou põe em quarentena um caminho do destino
+	discard := func(entryPath string) (string, error) {
+		targetPath := filepath.Join(target, filepath.FromSlash(entryPath))
+		if quarantine == "" {
+			if !options.DryRun {
+				if err := fsys.RemoveAll(targetPath); err != nil {
+					return "", fmt.Errorf("failed to remove '%s': %w", entryPath, err)
+				}
+			}
+			return "", nil
+		}
+		quarantinePath := filepath.Join(quarantine, filepath.FromSlash(entryPath))
+		if !options.DryRun {
+			if err := fsys.MkdirAll(filepath.Dir(quarantinePath), os.ModePerm); err != nil {
+				return "", fmt.Errorf("failed to create quarantine for '%s': %w", entryPath, err)
+			}
+			if err := fsys.Rename(targetPath, quarantinePath); err != nil {
+				return "", fmt.Errorf("failed to quarantine '%s': %w", entryPath, err)
+			}
+		}
+		return quarantinePath, nil
+	}
+
+	created := make(map[string]bool)
+	discarded := make(map[string]bool)
+	for _, entry := range tc.FileTree.GetEntries() {
+		entryPath := entry.GetPath()
+		targetPath, err := tc.TargetPath(entry)
+		if err != nil {
+			return nil, err
+		}
+		if isSyncCovered(entryPath, discarded) {
+			created[entryPath] = true
+			continue // Recriado junto com o diretório pai
+		}
+		existing := actual.GetEntryByPath(entryPath)
+		if existing == nil {
+			if isScanIgnored(entryPath, ignore) || isUnderIgnored(entryPath, ignore) {
+				continue
+			}
+			created[entryPath] = true
+			report.Actions = append(report.Actions, TreeSyncAction{Action: "create", Path: entryPath, Detail: entry.GetType()})
+			continue
+		}
+		switch {
+		case existing.GetType() != entry.GetType():
+			quarantinePath, err := discard(entryPath)
+			if err != nil {
+				return nil, err
+			}
+			discarded[entryPath], created[entryPath] = true, true
+			detail := fmt.Sprintf("%s -> %s", existing.GetType(), entry.GetType())
+			if quarantinePath != "" {
+				detail += ", quarantined at " + quarantinePath
+			}
+			report.Actions = append(report.Actions, TreeSyncAction{Action: "retype", Path: entryPath, Detail: detail})
+		case entry.GetType() == "symlink" && GetEntryMetadataString(existing, "linkTarget") != GetEntryMetadataString(entry, "linkTarget"):
+			if !options.DryRun {
+				if err := fsys.Remove(targetPath); err != nil {
+					return nil, fmt.Errorf("failed to remove symlink '%s': %w", entryPath, err)
+				}
+			}
+			created[entryPath] = true
+			report.Actions = append(report.Actions, TreeSyncAction{Action: "relink", Path: entryPath, Detail: fmt.Sprintf("%s -> %s", GetEntryMetadataString(existing, "linkTarget"), GetEntryMetadataString(entry, "linkTarget"))})
+		case HasExplicitPermissions(entry) && entry.GetType() != "symlink":
+			want, err := utl.ParsePermissions(entry.GetPermissions())
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse permissions for '%s': %w", entryPath, err)
+			}
+			if have, _ := utl.ParsePermissions(existing.GetPermissions()); have != want {
+				report.Actions = append(report.Actions, TreeSyncAction{Action: "chmod", Path: entryPath, Detail: fmt.Sprintf("%s -> %s", existing.GetPermissions(), utl.FormatPermissions(want))})
+			}
+		}
+	}
+
+	for _, extra := range actual.GetEntries() {
+		extraPath := extra.GetPath()
+		if tc.FileTree.GetEntryByPath(extraPath) != nil || isSyncCovered(extraPath, discarded) || isSourceCopy(tc.FileTree, extraPath) {
+			continue
+		}
+		if !options.Prune {
+			if !isSyncCovered(extraPath, toSet(report.Extras)) {
+				report.Extras = append(report.Extras, extraPath)
+			}
+			continue
+		}
+		quarantinePath, err := discard(extraPath)
+		if err != nil {
+			return nil, err
+		}
+		discarded[extraPath] = true
+		if quarantinePath != "" {
+			report.Actions = append(report.Actions, TreeSyncAction{Action: "quarantine", Path: extraPath, Detail: quarantinePath})
+		} else {
+			report.Actions = append(report.Actions, TreeSyncAction{Action: "prune", Path: extraPath, Detail: extra.GetType()})
+		}
+	}
+	if options.DryRun {
+		for _, action := range report.Actions {
+			gl.Log("info", fmt.Sprintf("[dry-run] %s %s (%s)", action.Action, action.Path, action.Detail))
+		}
+		return report, nil
+	}
+
+	// Compõe o que falta, sem tocar nos caminhos ignorados, com os hooks só para as entradas criadas agora
+	tc.ignored = options.Ignore
+	defer func() { tc.ignored = nil }()
+	if err := tc.makeTree(false, tc.locksTree()); err != nil {
+		return report, err
+	}
+	if !tc.Options.NoHooks {
+		if err := tc.runTreeHooks(func(entry it.IFileEntry) bool { return created[entry.GetPath()] }); err != nil {
+			return report, fmt.Errorf("failed to run hooks: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// isSyncCovered reports if a path is below one of the given paths.
+func isSyncCovered(entryPath string, paths map[string]bool) bool {
+	for dir := path.Dir(entryPath); dir != "."; dir = path.Dir(dir) {
+		if paths[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// isSourceCopy reports if a path is below a directory entry copied from a `from=` source.
+func isSourceCopy(ft *FileTree, entryPath string) bool {
+	for dir := path.Dir(entryPath); dir != "."; dir = path.Dir(dir) {
+		if entry := ft.GetEntryByPath(dir); entry != nil && GetEntryMetadataString(entry, "from") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// toSet returns the set of the given paths.
+func toSet(paths []string) map[string]bool {
+	set := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		set[p] = true
+	}
+	return set
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	utl "github.com/faelmori/cleandgo/utils"
+)
+
+func TestSyncTreeIgnore(t *testing.T) {
+	const target = "/work/app"
+	view := "build/\n├── new.txt\n└── run.sh  # @mode=0755\nsrc/\n├── main.go\n└── tool.sh  # @mode=0755"
+	for _, dryRun := range []bool{true, false} {
+		fsys := utl.NewMemFileSystem()
+		if err := fsys.MkdirAll(filepath.Join(target, "build"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.WriteFile(filepath.Join(target, "build", "run.sh"), []byte("#!/bin/sh\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ft := parseTestTree(t, view)
+		ft.ComposerTargetPath = target
+		tc := &TreeComposer{FileTree: ft, Options: &TreeComposerOptions{FS: fsys, NoJournal: true, NoSkeletons: true}}
+		report, err := tc.SyncTree(&TreeSyncOptions{Ignore: []string{"build"}, DryRun: dryRun})
+		if err != nil {
+			t.Fatalf("SyncTree(dryRun=%v) error = %v", dryRun, err)
+		}
+		actions := make(map[string]string)
+		for _, action := range report.Actions {
+			actions[action.Path] = action.Action
+		}
+		want := map[string]string{"src": "create", "src/main.go": "create", "src/tool.sh": "create"}
+		if len(actions) != len(want) {
+			t.Errorf("actions(dryRun=%v) = %v, want %v", dryRun, actions, want)
+		}
+		for entryPath, action := range want {
+			if actions[entryPath] != action {
+				t.Errorf("action(dryRun=%v) for %s = %q, want %q", dryRun, entryPath, actions[entryPath], action)
+			}
+		}
+		if dryRun {
+			continue
+		}
+
+		// Os caminhos ignorados não são criados nem alterados
+		if utl.CheckFileExistsIn(fsys, filepath.Join(target, "build", "new.txt")) {
+			t.Errorf("build/new.txt was created")
+		}
+		if info, err := fsys.Stat(filepath.Join(target, "build", "run.sh")); err != nil {
+			t.Errorf("Stat(build/run.sh) error = %v", err)
+		} else if info.Mode().Perm() != 0644 {
+			t.Errorf("build/run.sh mode = %v, want %v", info.Mode().Perm(), os.FileMode(0644))
+		}
+		if info, err := fsys.Stat(filepath.Join(target, "src", "tool.sh")); err != nil {
+			t.Errorf("Stat(src/tool.sh) error = %v", err)
+		} else if info.Mode().Perm() != 0755 {
+			t.Errorf("src/tool.sh mode = %v, want %v", info.Mode().Perm(), os.FileMode(0755))
+		}
+	}
+}
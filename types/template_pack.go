@@ -0,0 +1,159 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	gl "github.com/faelmori/cleandgo/logger"
+	utl "github.com/faelmori/cleandgo/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplatePackManifestName is the optional manifest of a template pack, mapping globs to templates.
+const TemplatePackManifestName = "pack.yaml"
+
+// TemplatePackMatch maps the entries matching a glob to a template of the pack.
+type TemplatePackMatch struct {
+	Match    string `json:"match" yaml:"match" xml:"match" toml:"match"`             // Glob do caminho da entrada
+	Template string `json:"template" yaml:"template" xml:"template" toml:"template"` // Nome do template no pacote
+}
+
+// TemplatePackManifest is the content of the pack manifest:
+//
+//	version: 1.2.0
+//	templates:
+//	  - match: "**/*_service.go"
+//	    template: go/service
+//	  - match: "**/Dockerfile"
+//	    template: docker/go
+type TemplatePackManifest struct {
+	Version   string              `json:"version,omitempty" yaml:"version,omitempty" xml:"version" toml:"version"` // Versão do pacote, gravada no lock
+	Templates []TemplatePackMatch `json:"templates" yaml:"templates" xml:"templates" toml:"templates"`
+}
+
+// TemplatePack is a versioned set of content templates, loaded from a directory or from an fs.FS (e.g. embed.FS).
+// Each "<name>.tmpl" file of the pack is a template named by its path without the extension, selected by the
+// `template=<name>` annotation of an entry or by the globs of the manifest. Templates may include each other.
+type TemplatePack struct {
+	Source   string               `json:"source" yaml:"source" xml:"source" toml:"source"`
+	Manifest TemplatePackManifest `json:"manifest" yaml:"manifest" xml:"manifest" toml:"manifest"`
+	Names    []string             `json:"names" yaml:"names" xml:"names" toml:"names"`
+	tmpl     *template.Template
+}
+
+// LoadTemplatePackDir loads a template pack from a directory.
+func LoadTemplatePackDir(packDir string) (*TemplatePack, error) {
+	if info, err := os.Stat(packDir); err != nil || !info.IsDir() {
+		gl.Log("error", fmt.Sprintf("Template pack directory not found: %s", packDir))
+		return nil, fmt.Errorf("template pack directory '%s' not found", packDir)
+	}
+	return LoadTemplatePack(os.DirFS(packDir), packDir)
+}
+
+// LoadTemplatePack loads a template pack from a file system, identified by the source in the messages.
+func LoadTemplatePack(fsys fs.FS, source string) (*TemplatePack, error) {
+	pack := &TemplatePack{
+		Source: source,
+		Names:  make([]string, 0),
+		tmpl:   template.New(source).Funcs(utl.TemplateFuncs()).Option("missingkey=error"),
+	}
+	err := fs.WalkDir(fsys, ".", func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+		if filePath == TemplatePackManifestName {
+			if err := yaml.Unmarshal(data, &pack.Manifest); err != nil {
+				return fmt.Errorf("failed to parse manifest: %w", err)
+			}
+			return nil
+		}
+		if path.Ext(filePath) != ".tmpl" {
+			return nil
+		}
+		name := strings.TrimSuffix(filePath, ".tmpl")
+		if _, err := pack.tmpl.New(name).Parse(string(data)); err != nil {
+			return fmt.Errorf("failed to parse template '%s': %w", name, err)
+		}
+		pack.Names = append(pack.Names, name)
+		return nil
+	})
+	if err != nil {
+		gl.Log("error", fmt.Sprintf("Failed to load template pack '%s': %s", source, err))
+		return nil, fmt.Errorf("failed to load template pack '%s': %w", source, err)
+	}
+	for _, match := range pack.Manifest.Templates {
+		if !pack.HasTemplate(match.Template) {
+			return nil, fmt.Errorf("template pack '%s' maps '%s' to unknown template '%s'", source, match.Match, match.Template)
+		}
+	}
+	return pack, nil
+}
+
+// HasTemplate reports if the pack has the named template.
+func (p *TemplatePack) HasTemplate(name string) bool {
+	return p.tmpl.Lookup(strings.TrimSuffix(name, ".tmpl")) != nil
+}
+
+// TemplateFor returns the template of the entry: the one of its `template` annotation, when the pack has it,
+// or the first one of the manifest whose glob matches the entry. It returns an empty name when none applies.
+func (p *TemplatePack) TemplateFor(ft it.IFileTree, entry it.IFileEntry) string {
+	if name := GetEntryMetadataString(entry, "template"); name != "" {
+		if p.HasTemplate(name) {
+			return strings.TrimSuffix(name, ".tmpl")
+		}
+		return ""
+	}
+	candidates := []string{entry.GetPath(), RootRelativePath(ft, entry)}
+	for _, match := range p.Manifest.Templates {
+		for _, candidate := range candidates {
+			if candidate != "" && utl.MatchGlob(match.Match, candidate) {
+				return strings.TrimSuffix(match.Template, ".tmpl")
+			}
+		}
+	}
+	return ""
+}
+
+// Render renders the named template with the data of an entry.
+func (p *TemplatePack) Render(name string, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := p.tmpl.ExecuteTemplate(&buf, strings.TrimSuffix(name, ".tmpl"), data); err != nil {
+		return "", fmt.Errorf("failed to render template '%s' of pack '%s': %w", name, p.Source, err)
+	}
+	return buf.String(), nil
+}
+
+// RenderTemplatePacks renders the content of an entry with the first pack that has a template for it.
+// An entry annotated with a template that none of the given packs provides is an error, also when no
+// packs are given.
+func RenderTemplatePacks(ft it.IFileTree, entry it.IFileEntry, packs []*TemplatePack, values map[string]any) (string, bool, error) {
+	for _, pack := range packs {
+		if name := pack.TemplateFor(ft, entry); name != "" {
+			content, err := pack.Render(name, NewTemplateData(entry, values))
+			if err != nil {
+				return "", false, fmt.Errorf("failed to render '%s': %w", entry.GetPath(), err)
+			}
+			return content, true, nil
+		}
+	}
+	if name := GetEntryMetadataString(entry, "template"); name != "" {
+		if len(packs) == 0 {
+			return "", false, fmt.Errorf("template '%s' of '%s' needs a template pack, none was given", name, entry.GetPath())
+		}
+		return "", false, fmt.Errorf("template '%s' of '%s' not found in the template packs", name, entry.GetPath())
+	}
+	return "", false, nil
+}
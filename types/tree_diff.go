@@ -0,0 +1,400 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+)
+
+// treeDiffSkippedKeys are the metadata keys kept by the parser for its own bookkeeping, left out of the diffs.
+var treeDiffSkippedKeys = map[string]bool{"id": true, "include": true, "rule": true, "ruleMatch": true}
+
+// TreeChange is a difference between two trees.
+type TreeChange struct {
+	Kind    string `json:"kind" yaml:"kind" xml:"kind" toml:"kind"`                                                     // "add", "remove", "move", "rename", "comment" ou "metadata"
+	Path    string `json:"path" yaml:"path" xml:"path" toml:"path"`                                                     // Caminho na árvore antiga (na nova, para as adições)
+	NewPath string `json:"newPath,omitempty" yaml:"newPath,omitempty" xml:"newPath,omitempty" toml:"newPath,omitempty"` // Caminho na árvore nova, quando mudou
+	Type    string `json:"type" yaml:"type" xml:"type" toml:"type"`                                                     // Tipo da entrada
+	Key     string `json:"key,omitempty" yaml:"key,omitempty" xml:"key,omitempty" toml:"key,omitempty"`                 // Chave dos metadados alterada
+	Old     any    `json:"old,omitempty" yaml:"old,omitempty" xml:"old,omitempty" toml:"old,omitempty"`                 // Valor antigo (comentário ou metadado)
+	New     any    `json:"new,omitempty" yaml:"new,omitempty" xml:"new,omitempty" toml:"new,omitempty"`                 // Valor novo (comentário ou metadado)
+}
+
+// TreeDiff is the structural difference between two trees. Entries are matched by path first, then by their
+// place under a matched parent, by their children and by their names, so subtrees that moved or were renamed
+// are reported once, instead of as a removal plus an addition of every entry.
+type TreeDiff struct {
+	Old     *FileTree                   // Árvore antiga
+	New     *FileTree                   // Árvore nova
+	Changes []TreeChange                // Mudanças, na ordem da árvore antiga, seguidas das adições
+	matches map[uuid.UUID]it.IFileEntry // Entrada nova correspondente a cada entrada antiga
+	matched map[uuid.UUID]bool          // Entradas novas com correspondente
+}
+
+// DiffTrees compares two trees, e.g. two versions of a tree view file or the snapshots of a directory.
+func DiffTrees(oldTree, newTree it.IFileTree) (*TreeDiff, error) {
+	oldFt, okOld := oldTree.GetFileTreeType().(*FileTree)
+	newFt, okNew := newTree.GetFileTreeType().(*FileTree)
+	if !okOld || !okNew {
+		return nil, fmt.Errorf("invalid file tree type")
+	}
+	d := &TreeDiff{
+		Old:     oldFt,
+		New:     newFt,
+		Changes: make([]TreeChange, 0),
+		matches: make(map[uuid.UUID]it.IFileEntry),
+		matched: make(map[uuid.UUID]bool),
+	}
+
+	for _, entry := range oldFt.GetEntries() {
+		if counterpart := newFt.GetEntryByPath(entry.GetPath()); counterpart != nil && counterpart.GetType() == entry.GetType() {
+			d.match(entry, counterpart)
+		}
+	}
+	// Os pais são casados antes dos filhos, que podem então acompanhá-los
+	pending := make([]it.IFileEntry, 0)
+	for _, entry := range oldFt.GetEntries() {
+		if _, ok := d.matches[entry.GetID()]; !ok {
+			pending = append(pending, entry)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pathDepth(pending[i].GetPath()) < pathDepth(pending[j].GetPath()) })
+	for _, entry := range pending {
+		if counterpart := d.findCounterpart(entry); counterpart != nil {
+			d.match(entry, counterpart)
+		}
+	}
+
+	for _, entry := range oldFt.GetEntries() {
+		d.Changes = append(d.Changes, d.entryChanges(entry)...)
+	}
+	for _, entry := range newFt.GetEntries() {
+		if d.matched[entry.GetID()] {
+			continue
+		}
+		if parent := treeParent(newFt, entry); parent != nil && !d.matched[parent.GetID()] {
+			continue // Adicionada junto com o diretório pai
+		}
+		d.Changes = append(d.Changes, TreeChange{Kind: "add", Path: entry.GetPath(), Type: entry.GetType()})
+	}
+	return d, nil
+}
+
+// match pairs an old entry with its new counterpart.
+func (d *TreeDiff) match(oldEntry, newEntry it.IFileEntry) {
+	d.matches[oldEntry.GetID()] = newEntry
+	d.matched[newEntry.GetID()] = true
+}
+
+// findCounterpart looks for the new entry of an old one, among the new entries of the same type not matched
+// yet: the entry with the same name under the counterpart of its parent, the directory with the same children,
+// the only entry with the same name, or an entry under the counterpart of its parent with a similar name (see
+// IsEqual) or, for directories, with most of the same children.
+func (d *TreeDiff) findCounterpart(entry it.IFileEntry) it.IFileEntry {
+	candidates := make([]it.IFileEntry, 0)
+	for _, candidate := range d.New.GetEntries() {
+		if !d.matched[candidate.GetID()] && candidate.GetType() == entry.GetType() {
+			candidates = append(candidates, candidate)
+		}
+	}
+	var newParentID uuid.UUID // Pai esperado na árvore nova (uuid.Nil na raiz)
+	parentKnown := true
+	if parent := treeParent(d.Old, entry); parent != nil {
+		counterpart, ok := d.matches[parent.GetID()]
+		if ok {
+			newParentID = counterpart.GetID()
+		}
+		parentKnown = ok
+	}
+	underParent := func(candidate it.IFileEntry) bool {
+		parent := treeParent(d.New, candidate)
+		return parentKnown && ((parent == nil && newParentID == uuid.Nil) || (parent != nil && parent.GetID() == newParentID))
+	}
+
+	for _, candidate := range candidates {
+		if underParent(candidate) && candidate.GetName() == entry.GetName() {
+			return candidate
+		}
+	}
+	children := treeChildNames(d.Old, entry)
+	if entry.GetType() == "directory" && len(children) > 0 {
+		var found it.IFileEntry
+		for _, candidate := range candidates {
+			if sameNames(children, treeChildNames(d.New, candidate)) {
+				if found != nil {
+					found = nil
+					break // Ambíguo
+				}
+				found = candidate
+			}
+		}
+		if found != nil {
+			return found
+		}
+	}
+	var sameName it.IFileEntry
+	for _, candidate := range candidates {
+		if candidate.GetName() == entry.GetName() {
+			if sameName != nil {
+				sameName = nil
+				break // Ambíguo
+			}
+			sameName = candidate
+		}
+	}
+	if sameName != nil {
+		return sameName
+	}
+	for _, candidate := range candidates {
+		if !underParent(candidate) {
+			continue
+		}
+		if IsEqual(entry.GetName(), candidate.GetName()) {
+			return candidate
+		}
+		if entry.GetType() == "directory" && len(children) > 0 && 2*sharedNames(children, treeChildNames(d.New, candidate)) >= maxL(len(children), len(treeChildNames(d.New, candidate))) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// entryChanges returns the changes of an old entry: its removal (only for the topmost removed entry), or the
+// move, rename, comment and metadata changes against its counterpart.
+func (d *TreeDiff) entryChanges(entry it.IFileEntry) []TreeChange {
+	parent := treeParent(d.Old, entry)
+	counterpart, ok := d.matches[entry.GetID()]
+	if !ok {
+		if parent != nil {
+			if _, parentKept := d.matches[parent.GetID()]; !parentKept {
+				return nil // Removida junto com o diretório pai
+			}
+		}
+		return []TreeChange{{Kind: "remove", Path: entry.GetPath(), Type: entry.GetType()}}
+	}
+
+	changes := make([]TreeChange, 0)
+	oldPath, newPath := entry.GetPath(), counterpart.GetPath()
+	if oldPath != newPath {
+		newParent := treeParent(d.New, counterpart)
+		sameParent := parent == nil && newParent == nil
+		if parent != nil && newParent != nil {
+			if parentCounterpart, kept := d.matches[parent.GetID()]; kept && parentCounterpart.GetID() == newParent.GetID() {
+				sameParent = true
+			}
+		}
+		switch {
+		case !sameParent:
+			changes = append(changes, TreeChange{Kind: "move", Path: oldPath, NewPath: newPath, Type: entry.GetType()})
+		case entry.GetName() != counterpart.GetName():
+			changes = append(changes, TreeChange{Kind: "rename", Path: oldPath, NewPath: newPath, Type: entry.GetType()})
+		}
+	}
+	if oldComment, newComment := strings.TrimSpace(EntryComments(entry)), strings.TrimSpace(EntryComments(counterpart)); oldComment != newComment {
+		changes = append(changes, TreeChange{Kind: "comment", Path: oldPath, NewPath: changedPath(oldPath, newPath), Type: entry.GetType(), Old: oldComment, New: newComment})
+	}
+	oldMeta, newMeta := EntryMetadata(entry), EntryMetadata(counterpart)
+	keys := make([]string, 0)
+	for key := range oldMeta {
+		keys = append(keys, key)
+	}
+	for key := range newMeta {
+		if _, ok := oldMeta[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if treeDiffSkippedKeys[key] {
+			continue
+		}
+		oldValue, newValue := oldMeta[key], newMeta[key]
+		oldData, _ := json.Marshal(oldValue)
+		newData, _ := json.Marshal(newValue)
+		if string(oldData) != string(newData) {
+			changes = append(changes, TreeChange{Kind: "metadata", Path: oldPath, NewPath: changedPath(oldPath, newPath), Type: entry.GetType(), Key: key, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+// changedPath returns the new path when it differs from the old one.
+func changedPath(oldPath, newPath string) string {
+	if oldPath == newPath {
+		return ""
+	}
+	return newPath
+}
+
+// TreeDiffRenderOptions holds the optional settings used while rendering a tree diff.
+type TreeDiffRenderOptions struct {
+	// Full renders the unchanged subtrees too, instead of only their directory line.
+	Full bool
+}
+
+// Render draws the new tree with a marker column: "+" for the added entries, "-" for the removed ones (drawn
+// under the counterpart of their old parent), ">" for the moved or renamed ones and "~" for the ones with
+// changed comments or metadata, followed by a note with the old values. Unchanged lines start with a space.
+func (d *TreeDiff) Render(options *TreeDiffRenderOptions) string {
+	if options == nil {
+		options = &TreeDiffRenderOptions{}
+	}
+	markers := make(map[uuid.UUID]string)
+	notes := make(map[uuid.UUID][]string)
+	ghosts := make(map[uuid.UUID][]it.IFileEntry) // Entradas removidas, pelo pai na árvore nova
+	dirty := make(map[uuid.UUID]bool)             // Entradas novas com mudanças abaixo delas
+	markDirty := func(entry it.IFileEntry) {
+		for entry = treeParent(d.New, entry); entry != nil; entry = treeParent(d.New, entry) {
+			dirty[entry.GetID()] = true
+		}
+	}
+	for _, entry := range d.New.GetEntries() {
+		if !d.matched[entry.GetID()] {
+			markers[entry.GetID()] = "+" // Inclusive as filhas das entradas adicionadas
+		}
+	}
+	for _, change := range d.Changes {
+		if change.Kind == "remove" {
+			removed := d.Old.GetEntryByPath(change.Path)
+			parentID := uuid.Nil
+			if parent := treeParent(d.Old, removed); parent != nil {
+				parentID = d.matches[parent.GetID()].GetID()
+				dirty[parentID] = true
+				markDirty(d.matches[parent.GetID()])
+			}
+			ghosts[parentID] = append(ghosts[parentID], removed)
+			continue
+		}
+		var entry it.IFileEntry
+		if change.Kind == "add" {
+			entry = d.New.GetEntryByPath(change.Path)
+		} else {
+			entry = d.matches[d.Old.GetEntryByPath(change.Path).GetID()]
+			switch change.Kind {
+			case "move":
+				markers[entry.GetID()] = ">"
+				notes[entry.GetID()] = append(notes[entry.GetID()], "moved from "+change.Path)
+			case "rename":
+				markers[entry.GetID()] = ">"
+				notes[entry.GetID()] = append(notes[entry.GetID()], "renamed from "+path.Base(change.Path))
+			case "comment":
+				if markers[entry.GetID()] == "" {
+					markers[entry.GetID()] = "~"
+				}
+				notes[entry.GetID()] = append(notes[entry.GetID()], fmt.Sprintf("comment was %q", change.Old))
+			case "metadata":
+				if markers[entry.GetID()] == "" {
+					markers[entry.GetID()] = "~"
+				}
+				notes[entry.GetID()] = append(notes[entry.GetID()], describeValueChange(change))
+			}
+		}
+		markDirty(entry)
+	}
+
+	var sb strings.Builder
+	var renderLevel func(entries []it.IFileEntry, removed []it.IFileEntry, prefix string, depth int)
+	renderLevel = func(entries []it.IFileEntry, removed []it.IFileEntry, prefix string, depth int) {
+		all := append(append([]it.IFileEntry{}, entries...), removed...)
+		for i, entry := range all {
+			ghost := i >= len(entries)
+			branch, continuation := "├── ", "│   "
+			if i == len(all)-1 {
+				branch, continuation = "└── ", "    "
+			}
+			if depth == 0 {
+				branch, continuation = "", ""
+			}
+			marker := markers[entry.GetID()]
+			if ghost {
+				marker = "-"
+			} else if marker == "" {
+				marker = " "
+			}
+			line := renderEntryLine(entry, &TreeRenderOptions{Comments: true})
+			if !ghost && len(notes[entry.GetID()]) > 0 {
+				line += "  (" + strings.Join(notes[entry.GetID()], "; ") + ")"
+			}
+			sb.WriteString(marker + " " + prefix + branch + line + "\n")
+			if entry.GetType() != "directory" {
+				continue
+			}
+			if ghost {
+				children := make([]it.IFileEntry, 0)
+				for _, child := range d.Old.GetChildren(entry.GetID()) {
+					if _, kept := d.matches[child.GetID()]; !kept {
+						children = append(children, child) // As filhas mantidas aparecem no novo lugar
+					}
+				}
+				renderLevel(nil, children, prefix+continuation, depth+1)
+				continue
+			}
+			if !options.Full && !dirty[entry.GetID()] && marker != "+" {
+				continue // Subárvore sem mudanças
+			}
+			renderLevel(d.New.GetChildren(entry.GetID()), ghosts[entry.GetID()], prefix+continuation, depth+1)
+		}
+	}
+	renderLevel(d.New.GetChildren(uuid.Nil), ghosts[uuid.Nil], "", 0)
+	return sb.String()
+}
+
+// describeValueChange describes a metadata change in a few words, leaving the long values out.
+func describeValueChange(change TreeChange) string {
+	format := func(value any) string {
+		if value == nil {
+			return "(none)"
+		}
+		return formatAnnotationValue(value)
+	}
+	oldText, newText := format(change.Old), format(change.New)
+	if len(oldText)+len(newText) > 60 || strings.ContainsAny(oldText+newText, "\n") {
+		return "@" + change.Key + " changed"
+	}
+	return fmt.Sprintf("@%s %s -> %s", change.Key, oldText, newText)
+}
+
+// treeParent returns the parent entry of an entry, or nil for the top level entries.
+func treeParent(ft *FileTree, entry it.IFileEntry) it.IFileEntry {
+	if entry == nil {
+		return nil
+	}
+	dir := path.Dir(entry.GetPath())
+	if dir == "." {
+		return nil
+	}
+	return ft.GetEntryByPath(dir)
+}
+
+// treeChildNames returns the sorted names of the children of an entry.
+func treeChildNames(ft *FileTree, entry it.IFileEntry) []string {
+	names := make([]string, 0)
+	for _, child := range ft.GetChildren(entry.GetID()) {
+		names = append(names, child.GetName())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sameNames reports if two sorted lists of names are equal.
+func sameNames(a, b []string) bool {
+	return len(a) == len(b) && sharedNames(a, b) == len(a)
+}
+
+// sharedNames counts the names present in both lists.
+func sharedNames(a, b []string) int {
+	set := toSet(b)
+	shared := 0
+	for _, name := range a {
+		if set[name] {
+			shared++
+		}
+	}
+	return shared
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffTrees(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     []string
+		new     []string
+		changes []string // Tipo, caminho e novo caminho das mudanças
+	}{
+		{
+			name:    "unchanged trees",
+			old:     []string{"app/", "└── x.go"},
+			new:     []string{"app/", "└── x.go"},
+			changes: []string{},
+		},
+		{
+			name:    "subtree moved as a whole",
+			old:     []string{"app/", "├── a/", "│   └── pkg/", "│       ├── x.go", "│       └── y.go", "└── b/"},
+			new:     []string{"app/", "├── a/", "└── b/", "    └── pkg/", "        ├── x.go", "        └── y.go"},
+			changes: []string{"move app/a/pkg -> app/b/pkg"},
+		},
+		{
+			name:    "directory renamed with the same children",
+			old:     []string{"app/", "└── util/", "    ├── x.go", "    └── y.go"},
+			new:     []string{"app/", "└── helpers/", "    ├── x.go", "    └── y.go"},
+			changes: []string{"rename app/util -> app/helpers"},
+		},
+		{
+			name:    "comment changed",
+			old:     []string{"app/", "└── x.go  # old"},
+			new:     []string{"app/", "└── x.go  # new"},
+			changes: []string{"comment app/x.go -> "},
+		},
+		{
+			name:    "entries added and removed",
+			old:     []string{"app/", "└── x.go"},
+			new:     []string{"app/", "└── docs/"},
+			changes: []string{"remove app/x.go -> ", "add app/docs -> "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := DiffTrees(parseTestTree(t, strings.Join(tt.old, "\n")), parseTestTree(t, strings.Join(tt.new, "\n")))
+			if err != nil {
+				t.Fatalf("DiffTrees() error = %v", err)
+			}
+			changes := make([]string, 0, len(diff.Changes))
+			for _, change := range diff.Changes {
+				changes = append(changes, change.Kind+" "+change.Path+" -> "+change.NewPath)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %q, want %q", changes, tt.changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	gl "github.com/faelmori/cleandgo/logger"
+	"github.com/subosito/gotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadTreeValues merges the template variables used by a tree file.
+// Precedence, from lowest to highest: values YAML file, env file and the CLI `--set k=v` pairs.
+func LoadTreeValues(sets []string, envFile, valuesFile string) (map[string]any, error) {
+	values := make(map[string]any)
+
+	if valuesFile != "" {
+		data, err := os.ReadFile(valuesFile)
+		if err != nil {
+			gl.Log("error", fmt.Sprintf("Failed to read values file: %s", err))
+			return nil, fmt.Errorf("failed to read values file '%s': %s", valuesFile, err)
+		}
+		fileValues := make(map[string]any)
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			gl.Log("error", fmt.Sprintf("Failed to parse values file: %s", err))
+			return nil, fmt.Errorf("failed to parse values file '%s': %s", valuesFile, err)
+		}
+		for k, v := range fileValues {
+			values[k] = v
+		}
+	}
+
+	if envFile != "" {
+		data, err := os.ReadFile(envFile)
+		if err != nil {
+			gl.Log("error", fmt.Sprintf("Failed to read env file: %s", err))
+			return nil, fmt.Errorf("failed to read env file '%s': %s", envFile, err)
+		}
+		envValues, err := gotenv.Unmarshal(string(data))
+		if err != nil {
+			gl.Log("error", fmt.Sprintf("Failed to parse env file: %s", err))
+			return nil, fmt.Errorf("failed to parse env file '%s': %s", envFile, err)
+		}
+		for k, v := range envValues {
+			values[k] = v
+		}
+	}
+
+	for _, set := range sets {
+		key, value, found := strings.Cut(set, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			gl.Log("error", fmt.Sprintf("Invalid value assignment: %s", set))
+			return nil, fmt.Errorf("invalid value assignment '%s', expected k=v", set)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
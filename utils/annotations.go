@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AnnotationKeys are the keys recognized in the `key=value` and `key: value` forms, with the kind
+// of their values ("string", "list", or "command", which takes the rest of the comment).
+// Any other key must use the explicit `@key=value` form.
+var AnnotationKeys = map[string]string{
+	"mode":     "string",
+	"owner":    "string",
+	"group":    "string",
+	"template": "string",
+	"checksum": "string",
+	"from":     "string",
+	"run":      "command",
+	"tags":     "list",
+}
+
+var (
+	// annotationKeyRegex matches the start of an annotation, used to find the commands (e.g. `run: go mod init x`),
+	// whose value is the rest of the comment.
+	annotationKeyRegex = regexp.MustCompile(`(?:^|\s)@?([A-Za-z_][\w.-]*)(?:=|:\s*)`)
+	// explicitAnnotationRegex matches `@key=value` and `@key="quoted value"`.
+	explicitAnnotationRegex = regexp.MustCompile(`(?:^|\s)@([A-Za-z_][\w.-]*)=("[^"]*"|'[^']*'|\S+)`)
+	// keyValueAnnotationRegex matches `key=value` and `key: value` (only for the recognized keys).
+	keyValueAnnotationRegex = regexp.MustCompile(`(?:^|\s)([A-Za-z_][\w.-]*)(?:=|:\s*)("[^"]*"|'[^']*'|[^\s,]+(?:,[^\s,]+)*)`)
+)
+
+// ParseAnnotations splits a comment into its free text and the structured annotations found in it,
+// e.g. "Payments API @owner=payments @mode=0750 @tags=api,public".
+func ParseAnnotations(comment string) (string, map[string]any) {
+	annotations := make(map[string]any)
+
+	text := comment
+	for _, match := range annotationKeyRegex.FindAllStringSubmatchIndex(comment, -1) {
+		key, value := comment[match[2]:match[3]], strings.TrimSpace(comment[match[1]:])
+		if AnnotationKeys[key] == "command" && value != "" {
+			annotations[key] = TypedAnnotationValue(key, value)
+			text = comment[:match[0]]
+			break
+		}
+	}
+	text = explicitAnnotationRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := explicitAnnotationRegex.FindStringSubmatch(match)
+		annotations[parts[1]] = TypedAnnotationValue(parts[1], parts[2])
+		return " "
+	})
+	text = keyValueAnnotationRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := keyValueAnnotationRegex.FindStringSubmatch(match)
+		if _, known := AnnotationKeys[parts[1]]; !known {
+			return match
+		}
+		annotations[parts[1]] = TypedAnnotationValue(parts[1], parts[2])
+		return " "
+	})
+
+	return strings.Join(strings.Fields(text), " "), annotations
+}
+
+// TypedAnnotationValue converts the raw value of an annotation into a bool, number, list or string.
+func TypedAnnotationValue(key, raw string) any {
+	if len(raw) >= 2 && (raw[0] == '"' || raw[0] == '\'') && raw[len(raw)-1] == raw[0] {
+		return raw[1 : len(raw)-1] // Valores entre aspas são sempre texto
+	}
+	kind := AnnotationKeys[key]
+	if kind == "list" || (kind == "" && strings.Contains(raw, ",")) {
+		items := make([]any, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	if kind == "string" || kind == "command" {
+		return raw
+	}
+	if raw == "true" || raw == "false" {
+		return raw == "true"
+	}
+	// Números com zero à esquerda (ex: modos octais) permanecem como texto
+	if !strings.HasPrefix(raw, "0") || raw == "0" {
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	}
+	return raw
+}
@@ -1,26 +1,213 @@
 package utils
 
-import "os"
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
 
-func CheckFileChecksum(filePath string, expectedChecksum string) (bool, error) {
-	// This function would typically compute the checksum of the file at filePath
-	// and compare it with expectedChecksum. For now, we return true for simplicity.
-	// In a real implementation, you would use a hashing library to compute the checksum.
-	return true, nil
+	it "github.com/faelmori/cleandgo/interfaces"
+)
+
+// checksumHashes are the supported checksum algorithms.
+var checksumHashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
 }
 
-func SetFileChecksum(filePath string, checksum string) error {
-	// This function would typically set the checksum for the file at filePath.
-	// For now, we do nothing and return nil for simplicity.
-	// In a real implementation, you would store the checksum in a metadata file or database.
-	return nil
+// ParseChecksum splits a checksum in its algorithm and hex digest. Checksums are written as "<algorithm>:<hex>"
+// (e.g. "sha256:9f86d0..."); the algorithm of a bare digest is inferred from its length.
+func ParseChecksum(checksum string) (string, string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	algorithm, digest, found := strings.Cut(checksum, ":")
+	if !found {
+		digest = algorithm
+		algorithm = map[int]string{32: "md5", 40: "sha1", 64: "sha256", 128: "sha512"}[len(digest)]
+	}
+	newHash, ok := checksumHashes[algorithm]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported checksum '%s', expected <md5|sha1|sha256|sha512>:<hex>", checksum)
+	}
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != newHash().Size()*2 {
+		return "", "", fmt.Errorf("invalid %s digest '%s'", algorithm, digest)
+	}
+	return algorithm, digest, nil
+}
+
+// FileChecksum computes the checksum of a file as "<algorithm>:<hex>" (sha256 when the algorithm is empty).
+func FileChecksum(fsys it.IFileSystem, filePath, algorithm string) (string, error) {
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+	newHash, ok := checksumHashes[strings.ToLower(algorithm)]
+	if !ok {
+		return "", fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
+	}
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := newHash()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return strings.ToLower(algorithm) + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckFileChecksum reports if the content of a file matches the expected checksum.
+func CheckFileChecksum(fsys it.IFileSystem, filePath string, expectedChecksum string) (bool, error) {
+	algorithm, digest, err := ParseChecksum(expectedChecksum)
+	if err != nil {
+		return false, err
+	}
+	actual, err := FileChecksum(fsys, filePath, algorithm)
+	if err != nil {
+		return false, err
+	}
+	return actual == algorithm+":"+digest, nil
+}
+
+// SetFileChecksum records the checksum of a file in a "<file>.<algorithm>" sidecar, in the format read by
+// `sha256sum -c` and friends. An empty checksum is computed (sha256); a given one must match the file.
+func SetFileChecksum(fsys it.IFileSystem, filePath string, checksum string) error {
+	if checksum == "" {
+		computed, err := FileChecksum(fsys, filePath, "")
+		if err != nil {
+			return err
+		}
+		checksum = computed
+	} else if ok, err := CheckFileChecksum(fsys, filePath, checksum); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("checksum '%s' does not match the content of '%s'", checksum, filePath)
+	}
+	algorithm, digest, err := ParseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+	sidecar := fmt.Sprintf("%s  %s\n", digest, filepath.Base(filePath))
+	return fsys.WriteFile(filePath+"."+algorithm, []byte(sidecar), 0644)
 }
 
 func ParsePermissions(permissions string) (os.FileMode, error) {
-	// This function would typically parse the permissions string and return
-	// the corresponding file mode. For now, we return 0644 for simplicity.
-	// In a real implementation, you would parse the string and convert it to a file mode.
-	return 0644, nil
+	permissions = strings.TrimSpace(permissions)
+	if permissions == "" {
+		return 0, fmt.Errorf("permissions cannot be empty")
+	}
+
+	// Formato octal: "0750", "750" ou "0o750"
+	if octal := strings.TrimPrefix(strings.TrimPrefix(permissions, "0o"), "0O"); strings.Trim(octal, "01234567") == "" {
+		mode, err := strconv.ParseUint(octal, 8, 32)
+		if err != nil || mode > 0o7777 {
+			return 0, fmt.Errorf("invalid octal permissions '%s'", permissions)
+		}
+		return fileModeFromUnix(uint32(mode)), nil
+	}
+
+	// Formato simbólico: "rwxr-x---", aceitando o caractere de tipo do `ls -l` ("-rwxr-x---", "drwxr-x---")
+	symbolic := permissions
+	if len(symbolic) == 10 {
+		symbolic = symbolic[1:]
+	}
+	if len(symbolic) != 9 {
+		return 0, fmt.Errorf("invalid permissions '%s'", permissions)
+	}
+	var mode uint32
+	for i, c := range symbolic {
+		bit := uint32(1) << uint(8-i)
+		expected := "rwx"[i%3]
+		switch {
+		case c == '-':
+		case byte(c) == expected:
+			mode |= bit
+		case i%3 == 2 && (c == 's' || c == 't'):
+			mode |= bit
+			mode |= map[int]uint32{2: 0o4000, 5: 0o2000, 8: 0o1000}[i]
+		case i%3 == 2 && (c == 'S' || c == 'T'):
+			mode |= map[int]uint32{2: 0o4000, 5: 0o2000, 8: 0o1000}[i]
+		default:
+			return 0, fmt.Errorf("invalid permissions '%s'", permissions)
+		}
+	}
+	return fileModeFromUnix(mode), nil
+}
+
+// FormatPermissions formats the permissions of a file mode in octal, as accepted by ParsePermissions.
+func FormatPermissions(mode os.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// fileModeFromUnix converts unix permission bits (including setuid, setgid and sticky) to an os.FileMode.
+func fileModeFromUnix(mode uint32) os.FileMode {
+	fileMode := os.FileMode(mode & 0o777)
+	if mode&0o4000 != 0 {
+		fileMode |= os.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		fileMode |= os.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		fileMode |= os.ModeSticky
+	}
+	return fileMode
+}
+
+// LookupOwnership resolves an owner and a group, given as names or numeric ids, to their ids (-1 when empty).
+func LookupOwnership(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		if id, err := strconv.Atoi(owner); err == nil {
+			uid = id
+		} else if u, err := user.Lookup(owner); err != nil {
+			return -1, -1, fmt.Errorf("unknown owner '%s': %w", owner, err)
+		} else if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, fmt.Errorf("unsupported uid '%s' for owner '%s'", u.Uid, owner)
+		}
+	}
+	if group != "" {
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		} else if g, err := user.LookupGroup(group); err != nil {
+			return -1, -1, fmt.Errorf("unknown group '%s': %w", group, err)
+		} else if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, fmt.Errorf("unsupported gid '%s' for group '%s'", g.Gid, group)
+		}
+	}
+	return uid, gid, nil
+}
+
+// ChownByName changes the owner and group of a path, given as names or numeric ids ("" keeps the current one).
+func ChownByName(fsys it.IFileSystem, path, owner, group string) error {
+	uid, gid, err := LookupOwnership(owner, group)
+	if err != nil {
+		return err
+	}
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	return fsys.Chown(path, uid, gid)
 }
 
 func CheckFileExists(path string) bool {
@@ -29,3 +216,76 @@ func CheckFileExists(path string) bool {
 	}
 	return false
 }
+
+// CheckFileExistsIn reports if a path exists in a filesystem (symlinks count, even when dangling).
+func CheckFileExistsIn(fsys it.IFileSystem, path string) bool {
+	_, err := fsys.Lstat(path)
+	return err == nil
+}
+
+// CopyFile copies a file between filesystems, optionally keeping the permissions of the source. Symlinks are
+// copied as symlinks.
+func CopyFile(srcFs it.IFileSystem, src string, dstFs it.IFileSystem, dst string, keepMode bool) error {
+	info, err := srcFs.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := srcFs.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return dstFs.Symlink(target, dst)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("'%s' is a directory", src)
+	}
+	in, err := srcFs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := dstFs.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if keepMode {
+		return dstFs.Chmod(dst, info.Mode().Perm()|info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+	}
+	return nil
+}
+
+// CopyDir copies the contents of a directory recursively between filesystems, keeping the files that already
+// exist in the target.
+func CopyDir(srcFs it.IFileSystem, src string, dstFs it.IFileSystem, dst string, keepMode bool) error {
+	return srcFs.Walk(src, func(srcPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, srcPath)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, rel)
+		if info.IsDir() {
+			if err := dstFs.MkdirAll(dstPath, os.ModePerm); err != nil {
+				return err
+			}
+			if keepMode {
+				return dstFs.Chmod(dstPath, info.Mode().Perm())
+			}
+			return nil
+		}
+		if CheckFileExistsIn(dstFs, dstPath) {
+			return nil // Não sobrescreve arquivos existentes
+		}
+		return CopyFile(srcFs, srcPath, dstFs, dstPath, keepMode)
+	})
+}
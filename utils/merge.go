@@ -0,0 +1,144 @@
+package utils
+
+import "strings"
+
+// maxMergeCells bounds the LCS table of MergeText; larger changes are merged as a single chunk.
+const maxMergeCells = 4 << 20
+
+// MergeText merges the changes made to a base text by two edited versions (ours and theirs), line by line
+// (diff3). Chunks changed by a single side, or identically by both, merge on their own; chunks changed
+// differently by both sides are written between "<<<<<<< <oursLabel>", "=======" and ">>>>>>> <theirsLabel>"
+// lines, and reported as conflicts.
+func MergeText(base, ours, theirs, oursLabel, theirsLabel string) (string, int) {
+	if ours == theirs || theirs == base {
+		return ours, 0
+	}
+	if ours == base {
+		return theirs, 0
+	}
+	baseLines, oursLines, theirsLines := splitTextLines(base), splitTextLines(ours), splitTextLines(theirs)
+	oursMatch := matchTextLines(baseLines, oursLines)
+	theirsMatch := matchTextLines(baseLines, theirsLines)
+
+	var out strings.Builder
+	conflicts := 0
+	// Resolve um trecho entre duas linhas estáveis (presentes e inalteradas nos três textos)
+	resolve := func(b, o, t []string) {
+		switch {
+		case sameLines(o, b):
+			writeTextLines(&out, t, false)
+		case sameLines(t, b), sameLines(o, t):
+			writeTextLines(&out, o, false)
+		default:
+			conflicts++
+			out.WriteString("<<<<<<< " + oursLabel + "\n")
+			writeTextLines(&out, o, true)
+			out.WriteString("=======\n")
+			writeTextLines(&out, t, true)
+			out.WriteString(">>>>>>> " + theirsLabel + "\n")
+		}
+	}
+	i, o, t := 0, 0, 0
+	for i < len(baseLines) {
+		if oursMatch[i] == o && theirsMatch[i] == t {
+			out.WriteString(baseLines[i])
+			i, o, t = i+1, o+1, t+1
+			continue
+		}
+		j := i
+		for j < len(baseLines) && (oursMatch[j] < 0 || theirsMatch[j] < 0) {
+			j++
+		}
+		if j == len(baseLines) {
+			break
+		}
+		resolve(baseLines[i:j], oursLines[o:oursMatch[j]], theirsLines[t:theirsMatch[j]])
+		i, o, t = j, oursMatch[j], theirsMatch[j]
+	}
+	if i < len(baseLines) || o < len(oursLines) || t < len(theirsLines) {
+		resolve(baseLines[i:], oursLines[o:], theirsLines[t:])
+	}
+	return out.String(), conflicts
+}
+
+// splitTextLines splits a text in lines, keeping the line breaks.
+func splitTextLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// writeTextLines writes the lines, breaking the last one when it has no line break and a marker follows.
+func writeTextLines(out *strings.Builder, lines []string, marked bool) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+	if marked && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// sameLines reports if two slices have the same lines.
+func sameLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTextLines maps each base line to its line in the edited text along their longest common subsequence,
+// or -1 when it was changed or removed.
+func matchTextLines(base, edited []string) []int {
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+	// Prefixo e sufixo comuns dispensam a tabela
+	prefix := 0
+	for prefix < len(base) && prefix < len(edited) && base[prefix] == edited[prefix] {
+		match[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(base)-prefix && suffix < len(edited)-prefix && base[len(base)-1-suffix] == edited[len(edited)-1-suffix] {
+		match[len(base)-1-suffix] = len(edited) - 1 - suffix
+		suffix++
+	}
+	b, e := base[prefix:len(base)-suffix], edited[prefix:len(edited)-suffix]
+	if len(b) == 0 || len(e) == 0 || len(b)*len(e) > maxMergeCells {
+		return match
+	}
+	width := len(e) + 1
+	lcs := make([]int32, (len(b)+1)*width)
+	for i := len(b) - 1; i >= 0; i-- {
+		for j := len(e) - 1; j >= 0; j-- {
+			if b[i] == e[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(b) && j < len(e); {
+		switch {
+		case b[i] == e[j]:
+			match[prefix+i] = prefix + j
+			i, j = i+1, j+1
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match
+}
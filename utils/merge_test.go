@@ -0,0 +1,77 @@
+package utils
+
+import "testing"
+
+func TestMergeText(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name: "changes by a single side",
+			base: "a\nb\nc\n", ours: "a\nb\nc\n", theirs: "a\nB\nc\n",
+			want: "a\nB\nc\n",
+		},
+		{
+			name: "changes in different places",
+			base: "a\nb\nc\nd\n", ours: "A\nb\nc\nd\n", theirs: "a\nb\nc\nD\n",
+			want: "A\nb\nc\nD\n",
+		},
+		{
+			name: "same insertion at the same point",
+			base: "a\nb\n", ours: "a\nx\nb\n", theirs: "a\nx\nb\n",
+			want: "a\nx\nb\n",
+		},
+		{
+			name: "different insertions at the same point",
+			base: "a\nb\n", ours: "a\nx\nb\n", theirs: "a\ny\nb\n",
+			want:      "a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\nb\n",
+			conflicts: 1,
+		},
+		{
+			name: "insertions at the end",
+			base: "a\n", ours: "a\nx\n", theirs: "a\ny\n",
+			want:      "a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name: "deletion against an edit of the same line",
+			base: "a\nb\nc\n", ours: "a\nc\n", theirs: "a\nB\nc\n",
+			want:      "a\n<<<<<<< ours\n=======\nB\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name: "deletion against an edit of another line",
+			base: "a\nb\nc\nd\n", ours: "b\nc\nd\n", theirs: "a\nb\nc\nD\n",
+			want: "b\nc\nD\n",
+		},
+		{
+			name: "missing trailing newline kept",
+			base: "a\nb", ours: "A\nb", theirs: "a\nb",
+			want: "A\nb",
+		},
+		{
+			name: "missing trailing newline merged with an edit elsewhere",
+			base: "a\nb\nc", ours: "A\nb\nc", theirs: "a\nb\nC",
+			want: "A\nb\nC",
+		},
+		{
+			name: "missing trailing newline in a conflict",
+			base: "a\nb", ours: "a\nx", theirs: "a\ny",
+			want:      "a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := MergeText(tt.base, tt.ours, tt.theirs, "ours", "theirs")
+			if got != tt.want || conflicts != tt.conflicts {
+				t.Errorf("MergeText() = %q, %d; want %q, %d", got, conflicts, tt.want, tt.conflicts)
+			}
+		})
+	}
+}
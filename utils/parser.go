@@ -32,7 +32,7 @@ func SetTreeViewEntriesDeepness(ft it.IFileTree) error {
 	for i, entry := range ft.GetEntries() {
 		matches := depthRegex.FindStringSubmatch(entry.GetOriginName())
 		if len(matches) > 0 {
-			depth := strings.Count(matches[1], "│") + strings.Count(matches[1], "├") + strings.Count(matches[1], "└")
+			depth := TreeLineDepth(matches[1])
 			ft.GetEntries()[i].SetDepth(depth)
 			if depth > maxDepth {
 				maxDepth = depth
@@ -63,10 +63,68 @@ func SetTreeViewEntriesDeepness(ft it.IFileTree) error {
 
 	return nil
 }
+
+var treeLinePrefixRegex = regexp.MustCompile(`^([\s│├└─]*)`)
+
+// TreeLinePrefix returns the drawing prefix (indentation and glyphs) of a tree line.
+func TreeLinePrefix(line string) string {
+	if matches := treeLinePrefixRegex.FindStringSubmatch(line); len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+// LineDepth returns the depth of a raw tree line.
+func LineDepth(line string) int {
+	return TreeLineDepth(TreeLinePrefix(line))
+}
+
+// TreeLineDepth returns the depth represented by the drawing prefix of a tree line.
+// Each glyph (│, ├, └) is a level, and so are the blank columns left under last children
+// (e.g. "    └── x" is two levels deep), which are counted in blocks of four spaces.
+func TreeLineDepth(prefix string) int {
+	depth, blanks := 0, 0
+	afterBar := false
+	flush := func() {
+		if afterBar {
+			// The first columns after a bar are its separator ("│   " or "│    ")
+			if blanks > 3 {
+				depth += (blanks - 3) / 4
+			}
+		} else {
+			depth += blanks / 4
+		}
+		blanks = 0
+	}
+	for _, r := range prefix {
+		switch r {
+		case ' ':
+			blanks++
+		case '\t':
+			blanks += 4
+		case '│':
+			flush()
+			depth++
+			afterBar = true
+		case '├', '└':
+			flush()
+			return depth + 1
+		case '─':
+			// Parte do conector horizontal, não altera a profundidade
+		default:
+			blanks = 0
+		}
+	}
+	flush()
+	return depth
+}
 func SetTreeViewDrawedIdentifiers(ft it.IFileTree) error {
-	// Define os IDs de cada entrada com base na posição na lista
+	// Define os IDs das entradas que ainda não possuem um, preservando os existentes
+	// para não invalidar as referências do mapa de origem e do diretório raiz
 	for i := range ft.GetEntries() {
-		ft.GetEntries()[i].SetID(uuid.New()) // Gera um novo UUID para cada entrada
+		if ft.GetEntries()[i].GetID() == uuid.Nil {
+			ft.GetEntries()[i].SetID(uuid.New()) // Gera um novo UUID para a entrada
+		}
 	}
 
 	// Define o ID do diretório raiz, se ainda não estiver definido
@@ -98,13 +156,34 @@ func SetTreeStructureReferences(ft it.IFileTree) error {
 		entryMap[ft.GetEntries()[i].GetID()] = ft.GetEntries()[i]
 	}
 
-	// Define as referências de estrutura para cada entrada
+	// Define as referências de estrutura para cada entrada.
+	// Entradas sem pai explícito são ligadas ao último diretório de menor profundidade (pilha por profundidade).
+	stack := make([]it.IFileEntry, 0)
 	for i := range ft.GetEntries() {
 		entry := ft.GetEntries()[i]
+		for len(stack) > 0 && stack[len(stack)-1].GetDepth() >= entry.GetDepth() {
+			stack = stack[:len(stack)-1]
+		}
 		if entry.GetParentID() != uuid.Nil {
 			if parent, ok := entryMap[entry.GetParentID()]; ok {
 				entry.SetParent(parent)
 			}
+		} else if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			if parent.GetType() != "directory" {
+				// Uma entrada com filhos é sempre um diretório
+				parent.SetType("directory")
+			}
+			entry.SetParent(parent)
+		}
+		stack = append(stack, entry)
+	}
+
+	// Entradas de tipo desconhecido sem filhos (ex: Dockerfile, Makefile) são arquivos
+	for i := range ft.GetEntries() {
+		entry := ft.GetEntries()[i]
+		if entry.GetType() == "unknown" && len(ft.GetChildren(entry.GetID())) == 0 {
+			entry.SetType("file")
 		}
 	}
 	return nil
@@ -138,3 +217,52 @@ func ContainsIcon(line string, icons []string) bool {
 	}
 	return false
 }
+
+// MatchGlob reports if a slash separated path matches a glob pattern.
+// Besides the path.Match syntax, "**" matches any number of directories.
+func MatchGlob(pattern, name string) bool {
+	re, err := regexp.Compile(globToRegex(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(strings.Trim(name, "/"))
+}
+func globToRegex(pattern string) string {
+	pattern = strings.Trim(strings.ReplaceAll(pattern, "\\", "/"), "/")
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				if i+2 < len(pattern) && pattern[i+2] == '/' {
+					sb.WriteString("(?:.*/)?") // "**/" também casa com nenhum diretório
+					i += 2
+				} else {
+					sb.WriteString(".*")
+					i++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				class := pattern[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				sb.WriteString("[" + class + "]")
+				i += end
+			} else {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// TemplateFuncs returns the helpers available to every tree template (names, comments and contents).
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"snake":  ToSnakeCase,
+		"kebab":  ToKebabCase,
+		"camel":  ToCamelCase,
+		"pascal": ToPascalCase,
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+		"title":  ToTitleCase,
+		"trim":   strings.TrimSpace,
+	}
+}
+
+// RenderTemplate renders a text/template with the tree helpers, failing on undefined variables.
+func RenderTemplate(name, text string, data any) (string, error) {
+	tmpl, err := template.New(name).Funcs(TemplateFuncs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template '%s': %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template '%s': %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// HasTemplateActions reports if the text contains template actions to be rendered.
+func HasTemplateActions(text string) bool {
+	return strings.Contains(text, "{{") && strings.Contains(text, "}}")
+}
+
+// splitWords breaks identifiers like "myHTTPServer", "my-server" or "my_server" into lower case words.
+func splitWords(s string) []string {
+	var words []string
+	var current []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r):
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			prevUpper := i > 0 && unicode.IsUpper(runes[i-1])
+			if prevLower || (prevUpper && nextLower) {
+				flush()
+			}
+			current = append(current, r)
+		default:
+			current = append(current, r)
+		}
+	}
+	flush()
+	return words
+}
+
+func ToSnakeCase(s string) string { return strings.Join(splitWords(s), "_") }
+func ToKebabCase(s string) string { return strings.Join(splitWords(s), "-") }
+func ToCamelCase(s string) string {
+	pascal := ToPascalCase(s)
+	if pascal == "" {
+		return ""
+	}
+	runes := []rune(pascal)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+func ToPascalCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, "")
+}
+func ToTitleCase(s string) string {
+	words := splitWords(s)
+	for i, w := range words {
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	it "github.com/faelmori/cleandgo/interfaces"
+	"github.com/spf13/afero"
+)
+
+// AferoFileSystem implements IFileSystem on top of an afero filesystem. Symlinks are supported when the
+// backend supports them (the OS filesystem does, the in-memory one does not).
+type AferoFileSystem struct {
+	Fs afero.Fs
+}
+
+// NewAferoFileSystem wraps an afero filesystem (e.g. a read-only or copy-on-write one) as an IFileSystem.
+func NewAferoFileSystem(fs afero.Fs) *AferoFileSystem {
+	return &AferoFileSystem{Fs: fs}
+}
+
+// NewOsFileSystem returns the filesystem of the operating system, the default one.
+func NewOsFileSystem() it.IFileSystem {
+	return NewAferoFileSystem(afero.NewOsFs())
+}
+
+// NewMemFileSystem returns an empty in-memory filesystem.
+func NewMemFileSystem() it.IFileSystem {
+	return NewAferoFileSystem(afero.NewMemMapFs())
+}
+
+// NewBasePathFileSystem returns the OS filesystem jailed in a base directory: every path, absolute ones
+// included, is relative to the base, and paths (or symlink targets) escaping it are rejected.
+func NewBasePathFileSystem(base string) it.IFileSystem {
+	base = filepath.Clean(base)
+	if abs, err := filepath.Abs(base); err == nil {
+		base = abs
+	}
+	bp := afero.NewBasePathFs(afero.NewOsFs(), base).(*afero.BasePathFs)
+	return &basePathFileSystem{AferoFileSystem: NewAferoFileSystem(bp), base: base, bp: bp}
+}
+
+// IsOsFileSystem reports if a filesystem is the (unjailed) filesystem of the operating system, looking
+// through the wrappers exposing an `Unwrap() IFileSystem` method.
+func IsOsFileSystem(fsys it.IFileSystem) bool {
+	if wrapper, ok := fsys.(interface{ Unwrap() it.IFileSystem }); ok {
+		return IsOsFileSystem(wrapper.Unwrap())
+	}
+	if a, ok := fsys.(*AferoFileSystem); ok {
+		_, isOs := a.Fs.(*afero.OsFs)
+		return isOs
+	}
+	return false
+}
+
+func (a *AferoFileSystem) Name() string { return a.Fs.Name() }
+
+func (a *AferoFileSystem) Open(name string) (io.ReadCloser, error) { return a.Fs.Open(name) }
+
+func (a *AferoFileSystem) Create(name string) (io.WriteCloser, error) { return a.Fs.Create(name) }
+
+func (a *AferoFileSystem) ReadFile(name string) ([]byte, error) { return afero.ReadFile(a.Fs, name) }
+
+func (a *AferoFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return afero.WriteFile(a.Fs, name, data, perm)
+}
+
+func (a *AferoFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return afero.ReadDir(a.Fs, name)
+}
+
+func (a *AferoFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return a.Fs.MkdirAll(path, perm)
+}
+
+func (a *AferoFileSystem) Stat(name string) (os.FileInfo, error) { return a.Fs.Stat(name) }
+
+func (a *AferoFileSystem) Lstat(name string) (os.FileInfo, error) {
+	if lstater, ok := a.Fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+	return a.Fs.Stat(name)
+}
+
+func (a *AferoFileSystem) Rename(oldname, newname string) error { return a.Fs.Rename(oldname, newname) }
+
+func (a *AferoFileSystem) Remove(name string) error { return a.Fs.Remove(name) }
+
+func (a *AferoFileSystem) RemoveAll(path string) error { return a.Fs.RemoveAll(path) }
+
+func (a *AferoFileSystem) Chmod(name string, mode os.FileMode) error { return a.Fs.Chmod(name, mode) }
+
+// Chown changes the owner of a path, without following symlinks on the OS filesystem.
+func (a *AferoFileSystem) Chown(name string, uid, gid int) error {
+	if _, isOs := a.Fs.(*afero.OsFs); isOs {
+		return os.Lchown(name, uid, gid)
+	}
+	return a.Fs.Chown(name, uid, gid)
+}
+
+func (a *AferoFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return a.Fs.Chtimes(name, atime, mtime)
+}
+
+func (a *AferoFileSystem) Symlink(oldname, newname string) error {
+	if linker, ok := a.Fs.(afero.Linker); ok {
+		return linker.SymlinkIfPossible(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: afero.ErrNoSymlink}
+}
+
+func (a *AferoFileSystem) Readlink(name string) (string, error) {
+	if reader, ok := a.Fs.(afero.LinkReader); ok {
+		return reader.ReadlinkIfPossible(name)
+	}
+	return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
+}
+
+// Walk walks the tree rooted at root in lexical order, without following symlinks.
+func (a *AferoFileSystem) Walk(root string, fn filepath.WalkFunc) error {
+	return afero.Walk(a.Fs, root, fn)
+}
+
+// basePathFileSystem keeps the relative symlink targets as they are (afero would resolve them against the
+// base), checking that they stay inside the jail.
+type basePathFileSystem struct {
+	*AferoFileSystem
+	base string
+	bp   *afero.BasePathFs
+}
+
+func (b *basePathFileSystem) Chown(name string, uid, gid int) error {
+	realName, err := b.bp.RealPath(name)
+	if err != nil {
+		return &os.PathError{Op: "chown", Path: name, Err: err}
+	}
+	return os.Lchown(realName, uid, gid)
+}
+
+func (b *basePathFileSystem) Symlink(oldname, newname string) error {
+	realNew, err := b.bp.RealPath(newname)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	target := oldname
+	if filepath.IsAbs(oldname) {
+		if target, err = b.bp.RealPath(oldname); err != nil {
+			return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+		}
+	} else if !b.contains(filepath.Join(filepath.Dir(realNew), oldname)) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+	return os.Symlink(target, realNew)
+}
+
+func (b *basePathFileSystem) Readlink(name string) (string, error) {
+	target, err := b.AferoFileSystem.Readlink(name)
+	if err != nil || !filepath.IsAbs(target) || !b.contains(target) {
+		return target, err
+	}
+	rel, err := filepath.Rel(b.base, target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(string(filepath.Separator), rel), nil
+}
+
+// contains reports if a real path is inside the base directory.
+func (b *basePathFileSystem) contains(realPath string) bool {
+	realPath = filepath.Clean(realPath)
+	return realPath == b.base || strings.HasPrefix(realPath, b.base+string(filepath.Separator))
+}